@@ -0,0 +1,221 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pashkov256/cmdpool/internal/config"
+	"github.com/rivo/tview"
+)
+
+// addPage is the name of the page with the form adding a command
+const addPage = "add"
+
+// restartPolicies are the choices of the restart field, the default first
+var restartPolicies = []string{
+	string(config.RestartNever),
+	string(config.RestartOnFailure),
+	string(config.RestartAlways),
+}
+
+// Labels of the fields of the add form
+const (
+	fieldName    = "Name"
+	fieldCommand = "Command"
+	fieldDir     = "Directory"
+	fieldEnv     = "Env"
+	fieldRestart = "Restart"
+	fieldPty     = "Terminal (pty)"
+	fieldSave    = "Save to config"
+	fieldSet     = "Command set"
+)
+
+// addNewCommand shows a form to define a command and starts it in a new
+// panel. With a config file loaded the command can also be saved into one
+// of its command sets.
+func (ui *TUI) addNewCommand() {
+	form := tview.NewForm().SetItemPadding(0)
+	form.AddInputField(fieldName, "", 40, nil, nil)
+	form.AddInputField(fieldCommand, "", 60, nil, nil)
+	form.AddInputField(fieldDir, "", 60, nil, nil)
+	form.AddInputField(fieldEnv, "", 60, nil, nil)
+	form.AddDropDown(fieldRestart, restartPolicies, 0, nil)
+	form.AddCheckbox(fieldPty, false, nil)
+
+	input := func(label string) *tview.InputField {
+		return form.GetFormItemByLabel(label).(*tview.InputField)
+	}
+	input(fieldName).SetPlaceholder("the command")
+	input(fieldDir).SetPlaceholder(".")
+	input(fieldEnv).SetPlaceholder("KEY=value ...")
+
+	// Complete directories like a shell: Tab takes the selected entry and
+	// lists its subdirectories
+	dir := input(fieldDir)
+	dir.SetAutocompleteFunc(completeDir)
+	dir.SetAutocompletedFunc(func(text string, index, source int) bool {
+		if source != tview.AutocompletedNavigate {
+			dir.SetText(text)
+		}
+		return source != tview.AutocompletedTab && source != tview.AutocompletedNavigate
+	})
+
+	canSave := ui.config != nil && len(ui.config.Files()) > 0
+	height := 12
+	if canSave {
+		set := ""
+		if panel := ui.selectedCommandPanel(); panel != nil {
+			if group := panel.command.Group(); group != nil {
+				set = group.Key
+			}
+		}
+		form.AddCheckbox(fieldSave, false, nil)
+		form.AddInputField(fieldSet, set, 30, nil, nil)
+		input(fieldSet).SetAutocompleteFunc(func(text string) []string {
+			var keys []string
+			for _, key := range ui.config.SetNames() {
+				if text != "" && text != key && strings.HasPrefix(key, text) {
+					keys = append(keys, key)
+				}
+			}
+			return keys
+		})
+		height += 2
+	}
+
+	form.AddButton("Start", func() {
+		save := canSave && form.GetFormItemByLabel(fieldSave).(*tview.Checkbox).IsChecked()
+		_, restart := form.GetFormItemByLabel(fieldRestart).(*tview.DropDown).GetCurrentOption()
+		entry := config.CommandEntry{
+			Name:    strings.TrimSpace(input(fieldName).GetText()),
+			Run:     strings.TrimSpace(input(fieldCommand).GetText()),
+			Dir:     strings.TrimSpace(dir.GetText()),
+			Restart: config.RestartPolicy(restart),
+			Pty:     form.GetFormItemByLabel(fieldPty).(*tview.Checkbox).IsChecked(),
+		}
+		if entry.Name == "" {
+			entry.Name = entry.Run
+		}
+
+		var err error
+		entry.Env, err = parseEnv(input(fieldEnv).GetText())
+		if err == nil {
+			if save {
+				err = ui.startSaved(strings.TrimSpace(input(fieldSet).GetText()), entry)
+			} else {
+				err = ui.startEntry(entry)
+			}
+		}
+		if err != nil {
+			ui.showMessage(fmt.Sprintf("Command not added: %v", err), tcell.ColorRed)
+			return
+		}
+		ui.closeDialog()
+	})
+	form.AddButton("Cancel", ui.closeDialog)
+	form.SetCancelFunc(ui.closeDialog)
+	form.SetBorder(true).SetTitle(" Add command ")
+
+	ui.showDialog(addPage, form, 80, height)
+}
+
+// checkEntry reports what keeps an entry from the add form from running
+func (ui *TUI) checkEntry(entry config.CommandEntry) error {
+	if entry.Run == "" {
+		return fmt.Errorf("enter a command to run")
+	}
+	if _, exists := ui.executor.GetCommands()[entry.Name]; exists {
+		return fmt.Errorf("a command named %q already exists", entry.Name)
+	}
+	if entry.Dir != "" {
+		if info, err := os.Stat(entry.Dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("directory %q does not exist", entry.Dir)
+		}
+	}
+	return nil
+}
+
+// startEntry starts a command from the add form in a new panel
+func (ui *TUI) startEntry(entry config.CommandEntry) error {
+	if err := ui.checkEntry(entry); err != nil {
+		return err
+	}
+	if entry.Dir == "" {
+		entry.Dir = "."
+	}
+
+	ui.AddCommand(ui.executor.Start(entry))
+	ui.selectPanel(len(ui.commandPanels) - 1)
+	ui.showMessage("Started "+entry.Name, tcell.ColorGreen)
+	return nil
+}
+
+// startSaved adds a command from the add form to a command set of the
+// config, writes it into the config file, leaving the rest of the file as
+// it is, and starts the command as defined there.
+// Reloading the saved file then adds the running command to its set.
+func (ui *TUI) startSaved(key string, entry config.CommandEntry) error {
+	if key == "" {
+		return fmt.Errorf("enter the command set to save the command in")
+	}
+	if err := ui.checkEntry(entry); err != nil {
+		return err
+	}
+
+	// Only what differs from the set's defaults is written
+	set := ui.config.CommandSets[key]
+	if entry.Restart == config.RestartNever && !set.AutoRestart {
+		entry.Restart = ""
+	}
+	if entry.Dir != "" && set.Dir != "" && !filepath.IsAbs(entry.Dir) {
+		if rel, err := filepath.Rel(set.Dir, entry.Dir); err == nil {
+			entry.Dir = rel
+		}
+	}
+
+	next, err := ui.config.WithCommand(key, entry)
+	if err != nil {
+		return err
+	}
+	if err := config.AddCommand(next.Files()[0], key, entry); err != nil {
+		return err
+	}
+	ui.config = next
+
+	entries := next.CommandSets[key].Entries(key)
+	if err := ui.startEntry(entries[len(entries)-1]); err != nil {
+		return err
+	}
+	ui.showMessage(fmt.Sprintf("Started %s (saved in %s)", entry.Name, key), tcell.ColorGreen)
+	return nil
+}
+
+// parseEnv reads KEY=value pairs separated by spaces
+func parseEnv(text string) ([]string, error) {
+	env := strings.Fields(text)
+	for _, pair := range env {
+		if key, _, found := strings.Cut(pair, "="); !found || key == "" {
+			return nil, fmt.Errorf("env %q is not KEY=value", pair)
+		}
+	}
+	return env, nil
+}
+
+// completeDir lists the directories whose path starts with text
+func completeDir(text string) []string {
+	if text == "" {
+		return nil
+	}
+	matches, _ := filepath.Glob(text + "*")
+
+	var dirs []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.IsDir() {
+			dirs = append(dirs, match+string(filepath.Separator))
+		}
+	}
+	return dirs
+}
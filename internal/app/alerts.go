@@ -0,0 +1,103 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pashkov256/cmdpool/internal/config"
+	"github.com/pashkov256/cmdpool/internal/executor"
+)
+
+// Timing of alert notifications: how long the border of a panel flashes
+// after an alert, how fast it blinks and how often the bell may ring
+const (
+	flashDuration = 2 * time.Second
+	flashInterval = 250 * time.Millisecond
+	bellInterval  = 2 * time.Second
+)
+
+// severityColor returns the colour alerts of a severity are shown in
+func severityColor(severity config.AlertSeverity) tcell.Color {
+	if severity == config.SeverityWarn {
+		return tcell.ColorYellow
+	}
+	return tcell.ColorRed
+}
+
+// checkAlerts notifies of alerts raised since the last check: the panel
+// flashes, the status bar shows the newest alert and rules with a bell
+// ring it. It reports whether anything changed.
+func (ui *TUI) checkAlerts() bool {
+	changed := false
+	bell := false
+	for _, panel := range ui.commandPanels {
+		_, _, seq := panel.command.AlertState()
+		if seq == panel.alertSeq {
+			continue
+		}
+		alerts := panel.command.AlertsSince(panel.alertSeq)
+		panel.alertSeq = seq
+		if len(alerts) == 0 {
+			continue
+		}
+
+		last := alerts[len(alerts)-1]
+		panel.flash(severityColor(last.Severity))
+		ui.showMessage(fmt.Sprintf("⚠ %s (%s): %s", panel.command.Name, last.Rule, last.Text), severityColor(last.Severity))
+		for _, alert := range alerts {
+			bell = bell || alert.Bell
+		}
+		changed = true
+	}
+
+	if bell && time.Since(ui.rang) >= bellInterval {
+		ui.rang = time.Now()
+		ui.bell.Store(true)
+	}
+	return changed
+}
+
+// ringBell rings the terminal bell if an alert asked for it. It is called
+// after every draw.
+func (ui *TUI) ringBell(screen tcell.Screen) {
+	if ui.bell.Swap(false) {
+		screen.Beep()
+	}
+}
+
+// ackAlerts acknowledges the alerts of a command, clearing its badge
+func (ui *TUI) ackAlerts(cmd *executor.Command) {
+	cmd.AckAlerts()
+	ui.updateUI()
+}
+
+// ackAllAlerts acknowledges the alerts of every command
+func (ui *TUI) ackAllAlerts() {
+	for _, panel := range ui.commandPanels {
+		panel.command.AckAlerts()
+	}
+	ui.updateUI()
+}
+
+// flash makes the border of the panel blink in a colour for a while
+func (panel *CommandPanel) flash(color tcell.Color) {
+	panel.flashColor = color
+	panel.flashUntil = time.Now().Add(flashDuration)
+}
+
+// flashing reports whether the border is drawn in the flash colour now
+func (panel *CommandPanel) flashing() bool {
+	now := time.Now()
+	return now.Before(panel.flashUntil) && panel.flashUntil.Sub(now)/flashInterval%2 == 0
+}
+
+// alertBadge returns the badge of a panel with unacknowledged alerts: a
+// warning sign and their number in the colour of the highest severity
+func (panel *CommandPanel) alertBadge() string {
+	count, severity, _ := panel.command.AlertState()
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("[#%06x]⚠ %d[-]", severityColor(severity).Hex(), count)
+}
@@ -0,0 +1,170 @@
+package app
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pashkov256/cmdpool/internal/executor"
+	"github.com/rivo/tview"
+)
+
+// savePage is the name of the page asking where to save output
+const savePage = "save"
+
+// exportTimeFormat is how the time in default export file names is written
+const exportTimeFormat = "20060102-150405"
+
+// saveOutput asks for a file and writes the whole output of the selected
+// command to it, including history spilled to disk
+func (ui *TUI) saveOutput() {
+	panel := ui.selectedCommandPanel()
+	if panel == nil {
+		return
+	}
+	cmd := panel.command
+	path := fmt.Sprintf("%s-%s.log", fileName(cmd.Name), time.Now().Format(exportTimeFormat))
+	ui.askPath(" Save output of "+tview.Escape(cmd.Name)+" ", path, func(path string) {
+		ui.writeFile(path, "output of "+cmd.Name, cmd.WriteOutput)
+	})
+}
+
+// exportArchive asks for a file and writes an archive of the output of
+// every command together with their metadata
+func (ui *TUI) exportArchive() {
+	path := fmt.Sprintf("cmdpool-%s.tar.gz", time.Now().Format(exportTimeFormat))
+	ui.askPath(" Export all output ", path, func(path string) {
+		ui.writeFile(path, "all output", ui.executor.WriteArchive)
+	})
+}
+
+// askPath shows a dialog asking for a file name and calls save with it
+func (ui *TUI) askPath(title, path string, save func(path string)) {
+	form := tview.NewForm().SetItemPadding(0)
+	form.AddInputField("File", path, 50, nil, nil)
+	input := form.GetFormItem(0).(*tview.InputField)
+
+	submit := func() {
+		path := strings.TrimSpace(input.GetText())
+		if path == "" {
+			return
+		}
+		ui.closeDialog()
+		save(path)
+	}
+	// Enter in the field saves right away
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			submit()
+		}
+	})
+	form.AddButton("Save", submit)
+	form.AddButton("Cancel", ui.closeDialog)
+	form.SetCancelFunc(ui.closeDialog)
+	form.SetBorder(true).SetTitle(title)
+
+	ui.showDialog(savePage, form, 64, 7)
+}
+
+// writeFile creates a file and fills it with write in the background, as
+// long output takes a while, and reports the outcome in the status bar
+func (ui *TUI) writeFile(path, what string, write func(w io.Writer) error) {
+	ui.showMessage(fmt.Sprintf("Saving %s to %s...", what, path), tcell.ColorYellow)
+	go func() {
+		err := createFile(path, write)
+		ui.app.QueueUpdateDraw(func() {
+			if err != nil {
+				ui.showMessage(fmt.Sprintf("Failed to save %s: %v", what, err), tcell.ColorRed)
+				return
+			}
+			ui.showMessage(fmt.Sprintf("Saved %s to %s", what, path), tcell.ColorGreen)
+		})
+	}()
+}
+
+// createFile creates a file and fills it with write. The file is removed
+// again if writing fails.
+func createFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// copyVisible copies the lines shown in the timeline, the full screen view
+// or the selected panel to the clipboard
+func (ui *TUI) copyVisible() {
+	var output *outputView
+	switch panel := ui.selectedCommandPanel(); {
+	case ui.timeline != nil:
+		output = ui.timeline.output
+	case ui.zoom != nil:
+		output = ui.zoom.output
+	case panel != nil:
+		output = panel.output
+	default:
+		return
+	}
+
+	lines := output.visibleLines()
+	if len(lines) == 0 {
+		ui.showMessage("Nothing to copy", tcell.ColorYellow)
+		return
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(plainLine(line) + "\n")
+	}
+	// The terminal is written to between draws, see flushClipboard
+	text := b.String()
+	ui.clipboard.Store(&text)
+	ui.showMessage(fmt.Sprintf("Copied %d lines to the clipboard", len(lines)), tcell.ColorGreen)
+}
+
+// plainLine writes a line of an output view like a saved log line, with
+// the command it came from in front in views of several commands
+func plainLine(line outputLine) string {
+	text := executor.FormatLine(executor.OutputLine{Stream: line.stream, Time: line.time, Level: line.level, Text: line.text})
+	if line.name != "" {
+		return line.name + " │ " + text
+	}
+	return text
+}
+
+// flushClipboard sends text copied since the last draw to the terminal.
+// It is called after every draw, so the escape sequence does not end up
+// in the middle of screen updates.
+func (ui *TUI) flushClipboard() {
+	if text := ui.clipboard.Swap(nil); text != nil {
+		writeClipboard(os.Stdout, *text)
+	}
+}
+
+// writeClipboard sends text to the clipboard of the terminal with an OSC 52
+// escape sequence, which also works over ssh
+func writeClipboard(w io.Writer, text string) error {
+	_, err := fmt.Fprintf(w, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	return err
+}
+
+// fileName makes a command name safe to use in a file name
+func fileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>| `, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,484 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pashkov256/cmdpool/internal/config"
+	"github.com/rivo/tview"
+)
+
+// helpPage is the name of the page listing the key bindings
+const helpPage = "help"
+
+// keymap holds the key bindings of every context
+type keymap struct {
+	bindings map[config.KeyContext][]config.KeyBinding
+	// actions maps the key names of a context to their action
+	actions map[config.KeyContext]map[string]string
+}
+
+// newKeymap builds the keymap of a keys config, which may be nil
+func newKeymap(keys *config.KeysConfig) *keymap {
+	k := &keymap{
+		bindings: make(map[config.KeyContext][]config.KeyBinding),
+		actions:  make(map[config.KeyContext]map[string]string),
+	}
+	for _, context := range config.KeyContexts {
+		k.bindings[context] = keys.Bindings(context)
+		k.actions[context] = make(map[string]string)
+		for _, binding := range k.bindings[context] {
+			for _, key := range binding.Keys {
+				if _, exists := k.actions[context][key]; !exists {
+					k.actions[context][key] = binding.Action
+				}
+			}
+		}
+	}
+	return k
+}
+
+// action returns the action bound to a key event, or "" if there is none
+func (k *keymap) action(context config.KeyContext, event *tcell.EventKey) string {
+	return k.actions[context][keyName(event)]
+}
+
+// keys returns the keys bound to an action
+func (k *keymap) keys(context config.KeyContext, action string) []string {
+	for _, binding := range k.bindings[context] {
+		if binding.Action == action {
+			return binding.Keys
+		}
+	}
+	return nil
+}
+
+// specialKeyNames are the names of keys that do not type a character, as
+// written in the keys config
+var specialKeyNames = map[tcell.Key]string{
+	tcell.KeyEnter:      "enter",
+	tcell.KeyEscape:     "esc",
+	tcell.KeyTab:        "tab",
+	tcell.KeyBacktab:    "backtab",
+	tcell.KeyBackspace:  "backspace",
+	tcell.KeyBackspace2: "backspace",
+	tcell.KeyDelete:     "delete",
+	tcell.KeyInsert:     "insert",
+	tcell.KeyHome:       "home",
+	tcell.KeyEnd:        "end",
+	tcell.KeyPgUp:       "pgup",
+	tcell.KeyPgDn:       "pgdn",
+	tcell.KeyUp:         "up",
+	tcell.KeyDown:       "down",
+	tcell.KeyLeft:       "left",
+	tcell.KeyRight:      "right",
+}
+
+// keyName returns the name of a key event in the form of
+// config.NormalizeKey, or "" for keys that cannot be bound
+func keyName(event *tcell.EventKey) string {
+	mods := event.Modifiers()
+	var name string
+	switch key := event.Key(); {
+	case key == tcell.KeyRune:
+		name = string(event.Rune())
+		if event.Rune() == ' ' {
+			name = "space"
+		}
+		// Shift is already part of the character
+		mods &^= tcell.ModShift | tcell.ModCtrl
+	case specialKeyNames[key] != "":
+		name = specialKeyNames[key]
+	case key >= tcell.KeyF1 && key <= tcell.KeyF12:
+		name = fmt.Sprintf("f%d", key-tcell.KeyF1+1)
+	case key >= tcell.KeyCtrlA && key <= tcell.KeyCtrlZ:
+		name = string(rune('a' + key - tcell.KeyCtrlA))
+		mods = mods&tcell.ModAlt | tcell.ModCtrl
+	default:
+		return ""
+	}
+
+	var b strings.Builder
+	if mods&tcell.ModCtrl != 0 {
+		b.WriteString("ctrl+")
+	}
+	if mods&tcell.ModAlt != 0 {
+		b.WriteString("alt+")
+	}
+	if mods&tcell.ModShift != 0 {
+		b.WriteString("shift+")
+	}
+	b.WriteString(name)
+	return b.String()
+}
+
+// keyDisplayNames are special keys as shown in the help
+var keyDisplayNames = map[string]string{
+	"up": "↑", "down": "↓", "left": "←", "right": "→",
+	"pgup": "PgUp", "pgdn": "PgDn", "backtab": "Shift-Tab",
+}
+
+// displayKey returns a key name as shown in the help, such as "Ctrl-F" or
+// "↑"
+func displayKey(key string) string {
+	parts := strings.Split(key, "+")
+	// The "+" key splits into two empty parts
+	if strings.HasSuffix(key, "+") {
+		parts = append(parts[:len(parts)-2], "+")
+	}
+	ctrl := strings.HasPrefix(key, "ctrl+")
+	for i, part := range parts {
+		switch {
+		case keyDisplayNames[part] != "":
+			parts[i] = keyDisplayNames[part]
+		case len(part) > 1:
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		case ctrl:
+			// Letters with ctrl are shown in upper case like terminals do
+			parts[i] = strings.ToUpper(part)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// actionDescriptions describe the actions in the help
+var actionDescriptions = map[string]string{
+	config.ActionPrev:       "Select the previous panel",
+	config.ActionNext:       "Select the next panel",
+	config.ActionZoom:       "Show the panel full screen",
+	config.ActionClose:      "Back to all panels",
+	config.ActionFollow:     "Follow the newest output or pause",
+	config.ActionScrollUp:   "Scroll up a line",
+	config.ActionScrollDown: "Scroll down a line",
+	config.ActionPageUp:     "Scroll up a page",
+	config.ActionPageDown:   "Scroll down a page",
+	config.ActionTop:        "Scroll to the oldest line",
+	config.ActionBottom:     "Scroll to the newest line",
+	config.ActionSearch:     "Search the output of the panel",
+	config.ActionSearchAll:  "Search the output of all panels",
+	config.ActionNextMatch:  "Select the next match",
+	config.ActionPrevMatch:  "Select the previous match",
+	config.ActionFilter:     "Show only lines with matches",
+	config.ActionStreams:    "Show all output, only stdout or only stderr",
+	config.ActionLevels:     "Show log lines of every level, info and above, warn and above or errors",
+	config.ActionTimestamps: "Show line times: absolute, relative to start, since previous line or none",
+	config.ActionLayout:     "Switch to the next layout",
+	config.ActionTimeline:   "Show the output of all commands merged by time",
+	config.ActionFreeze:     "Freeze the timeline or take new lines again",
+	config.ActionSelect:     "Choose the commands of the timeline",
+	config.ActionRestart:    "Restart the command",
+	config.ActionStop:       "Stop the command",
+	config.ActionSave:       "Save the whole output of the command to a file",
+	config.ActionCopy:       "Copy the lines in view to the clipboard",
+	config.ActionExport:     "Export the output of all commands as an archive",
+	config.ActionAdd:        "Run a new command",
+	config.ActionHelp:       "Show the key bindings",
+	config.ActionPalette:    "Find and run any action",
+	config.ActionQuit:       "Quit",
+	config.ActionSubmit:     "Accept the pattern",
+	config.ActionCancel:     "Cancel",
+}
+
+// helpBarItem is an entry of a help bar: a label for one or more actions
+type helpBarItem struct {
+	actions []string
+	label   string
+}
+
+// helpBarItems are the entries of the help bar of each context
+var helpBarItems = map[config.KeyContext][]helpBarItem{
+	config.KeyContextList: {
+		{[]string{config.ActionPrev, config.ActionNext}, "Navigate"},
+		{[]string{config.ActionZoom}, "Expand"},
+		{[]string{config.ActionSearch}, "Search"},
+		{[]string{config.ActionRestart}, "Restart"},
+		{[]string{config.ActionStop}, "Stop"},
+		{[]string{config.ActionAdd}, "Add"},
+		{[]string{config.ActionPalette}, "Commands"},
+		{[]string{config.ActionHelp}, "Help"},
+		{[]string{config.ActionQuit}, "Quit"},
+	},
+	config.KeyContextZoom: {
+		{[]string{config.ActionClose}, "Back"},
+		{[]string{config.ActionScrollUp, config.ActionScrollDown}, "Scroll"},
+		{[]string{config.ActionFollow}, "Follow"},
+		{[]string{config.ActionSearch}, "Search"},
+		{[]string{config.ActionNextMatch, config.ActionPrevMatch}, "Next/Prev"},
+		{[]string{config.ActionFilter}, "Filter"},
+		{[]string{config.ActionRestart}, "Restart"},
+		{[]string{config.ActionStop}, "Stop"},
+		{[]string{config.ActionHelp}, "Help"},
+	},
+	config.KeyContextTimeline: {
+		{[]string{config.ActionClose}, "Back"},
+		{[]string{config.ActionScrollUp, config.ActionScrollDown}, "Scroll"},
+		{[]string{config.ActionFreeze}, "Freeze"},
+		{[]string{config.ActionSearch}, "Search"},
+		{[]string{config.ActionFilter}, "Filter"},
+		{[]string{config.ActionSelect}, "Commands"},
+		{[]string{config.ActionTimestamps}, "Times"},
+		{[]string{config.ActionHelp}, "Help"},
+	},
+}
+
+// helpBarText returns the help bar of a context, showing the first key of
+// each action
+func (k *keymap) helpBarText(context config.KeyContext) string {
+	var items []string
+	for _, item := range helpBarItems[context] {
+		var keys []string
+		for _, action := range item.actions {
+			if bound := k.keys(context, action); len(bound) > 0 {
+				keys = append(keys, displayKey(bound[0]))
+			}
+		}
+		if len(keys) > 0 {
+			items = append(items, strings.Join(keys, "/")+": "+item.label)
+		}
+	}
+	return strings.Join(items, " | ")
+}
+
+// helpText lists every binding of a context followed by those used while
+// searching
+func (k *keymap) helpText(context config.KeyContext) string {
+	var b strings.Builder
+	section := func(title string, context config.KeyContext) {
+		fmt.Fprintf(&b, "[yellow::b]%s[-::-]\n", title)
+		for _, binding := range k.bindings[context] {
+			keys := make([]string, len(binding.Keys))
+			for i, key := range binding.Keys {
+				keys[i] = displayKey(key)
+			}
+			fmt.Fprintf(&b, "  %-20s %s\n", tview.Escape(strings.Join(keys, ", ")), actionDescriptions[binding.Action])
+		}
+	}
+
+	switch context {
+	case config.KeyContextZoom:
+		section("Full screen", context)
+	case config.KeyContextTimeline:
+		section("Timeline", context)
+	default:
+		section("Panels", context)
+	}
+	b.WriteString("\n")
+	section("While searching", config.KeyContextSearch)
+	return b.String()
+}
+
+// showHelp lists the key bindings of a context in a box on top of the
+// current view. Esc, Enter or a help key close it.
+func (ui *TUI) showHelp(context config.KeyContext) {
+	text := ui.keys.helpText(context)
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetText(text)
+	view.SetBorder(true).SetTitle(" Keys ")
+
+	view.SetDoneFunc(func(key tcell.Key) {
+		ui.closeDialog()
+	})
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if ui.keys.action(context, event) == config.ActionHelp {
+			ui.closeDialog()
+			return nil
+		}
+		return event
+	})
+
+	ui.showDialog(helpPage, view, 64, strings.Count(text, "\n")+2)
+}
+
+// keyContext returns the context of the key bindings that apply to the
+// focused view. Views without bindings, such as dialogs, get no context.
+func (ui *TUI) keyContext() (config.KeyContext, bool) {
+	if input, typing := ui.app.GetFocus().(*tview.InputField); typing {
+		if input == ui.searchInput {
+			return config.KeyContextSearch, true
+		}
+		return config.KeyContextInput, true
+	}
+
+	switch name, _ := ui.pages.GetFrontPage(); name {
+	case "main":
+		return config.KeyContextList, true
+	case zoomPage:
+		return config.KeyContextZoom, true
+	case timelinePage:
+		return config.KeyContextTimeline, true
+	}
+	return "", false
+}
+
+// runAction performs an action of a context and reports whether it did
+func (ui *TUI) runAction(context config.KeyContext, action string) bool {
+	switch context {
+	case config.KeyContextSearch:
+		switch action {
+		case config.ActionSubmit:
+			ui.finishSearch(false)
+		case config.ActionCancel:
+			ui.finishSearch(true)
+		case config.ActionNextMatch:
+			ui.withSearch(func(v *searchable) { v.nextMatch(1) })
+		case config.ActionPrevMatch:
+			ui.withSearch(func(v *searchable) { v.nextMatch(-1) })
+		default:
+			return false
+		}
+		return true
+
+	case config.KeyContextInput:
+		if action == config.ActionCancel {
+			ui.closeDialog()
+			return true
+		}
+		return false
+
+	case config.KeyContextTimeline:
+		return ui.runTimelineAction(context, action)
+
+	case config.KeyContextZoom:
+		if ui.zoom == nil {
+			return false
+		}
+		output := ui.zoom.output
+		switch action {
+		case config.ActionClose:
+			ui.closeZoom()
+			return true
+		case config.ActionFollow:
+			ui.zoom.toggleFollow()
+			return true
+		case config.ActionScrollUp:
+			output.scroll(-1)
+		case config.ActionScrollDown:
+			output.scroll(1)
+		case config.ActionPageUp:
+			output.scroll(-output.page())
+		case config.ActionPageDown:
+			output.scroll(output.page())
+		case config.ActionTop:
+			output.scrollToTop()
+		case config.ActionBottom:
+			output.setFollow(true)
+		default:
+			return ui.runCommonAction(context, action)
+		}
+		ui.zoom.update()
+		return true
+	}
+
+	switch action {
+	case config.ActionPrev:
+		ui.selectPreviousPanel()
+	case config.ActionNext:
+		ui.selectNextPanel()
+	case config.ActionZoom:
+		ui.toggleZoom()
+	case config.ActionLayout:
+		ui.cycleLayout()
+	case config.ActionAdd:
+		ui.addNewCommand()
+	default:
+		return ui.runCommonAction(context, action)
+	}
+	return true
+}
+
+// runTimelineAction performs an action of the timeline and reports whether
+// it did
+func (ui *TUI) runTimelineAction(context config.KeyContext, action string) bool {
+	if ui.timeline == nil {
+		return false
+	}
+	output := ui.timeline.output
+	switch action {
+	case config.ActionClose:
+		ui.closeTimeline()
+		return true
+	case config.ActionFreeze:
+		ui.timeline.toggleFreeze()
+		return true
+	case config.ActionSelect:
+		ui.selectTimelineCommands()
+		return true
+	case config.ActionStreams:
+		ui.timeline.show.stream = nextStream(ui.timeline.show.stream)
+	case config.ActionLevels:
+		ui.timeline.show.level = nextLevel(ui.timeline.show.level)
+	case config.ActionFollow:
+		output.setFollow(!output.following())
+	case config.ActionScrollUp:
+		output.scroll(-1)
+	case config.ActionScrollDown:
+		output.scroll(1)
+	case config.ActionPageUp:
+		output.scroll(-output.page())
+	case config.ActionPageDown:
+		output.scroll(output.page())
+	case config.ActionTop:
+		output.scrollToTop()
+	case config.ActionBottom:
+		output.setFollow(true)
+	default:
+		return ui.runCommonAction(context, action)
+	}
+	ui.timeline.update()
+	return true
+}
+
+// runCommonAction performs the actions shared by the panels and the full
+// screen view
+func (ui *TUI) runCommonAction(context config.KeyContext, action string) bool {
+	switch action {
+	case config.ActionSearch:
+		ui.startSearch(false)
+	case config.ActionSearchAll:
+		ui.startSearch(true)
+	case config.ActionNextMatch:
+		ui.withSearch(func(v *searchable) { v.nextMatch(1) })
+	case config.ActionPrevMatch:
+		ui.withSearch(func(v *searchable) { v.nextMatch(-1) })
+	case config.ActionFilter:
+		ui.withSearch((*searchable).toggleFilter)
+	case config.ActionStreams:
+		ui.withSelectedPanel((*CommandPanel).cycleStream)
+	case config.ActionLevels:
+		ui.withSelectedPanel((*CommandPanel).cycleLevel)
+	case config.ActionTimestamps:
+		ui.cycleTimestamps()
+	case config.ActionTimeline:
+		ui.toggleTimeline()
+	case config.ActionRestart:
+		ui.restartSelectedCommand()
+	case config.ActionStop:
+		ui.stopSelectedCommand()
+	case config.ActionSave:
+		ui.saveOutput()
+	case config.ActionCopy:
+		ui.copyVisible()
+	case config.ActionExport:
+		ui.exportArchive()
+	case config.ActionHelp:
+		ui.showHelp(context)
+	case config.ActionPalette:
+		ui.showPalette()
+	case config.ActionQuit:
+		ui.quit()
+	default:
+		return false
+	}
+	return true
+}
+
+// applyKeys takes the key bindings of a config and updates the help bars
+func (ui *TUI) applyKeys(keys *config.KeysConfig) {
+	ui.keys = newKeymap(keys)
+	ui.helpBar.SetText(ui.keys.helpBarText(config.KeyContextList))
+	if ui.zoom != nil {
+		ui.zoom.helpBar.SetText(ui.keys.helpBarText(config.KeyContextZoom))
+	}
+}
@@ -0,0 +1,212 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pashkov256/cmdpool/internal/config"
+	"github.com/rivo/tview"
+)
+
+// arrangePanels fills the panels area according to the layout mode
+func (ui *TUI) arrangePanels() {
+	ui.panelsArea.Clear()
+	if len(ui.commandPanels) == 0 {
+		return
+	}
+
+	switch ui.layoutMode {
+	case config.LayoutStack:
+		ui.panelsArea.AddItem(flexOf(tview.FlexRow, ui.commandPanels), 0, 1, false)
+	case config.LayoutSplit:
+		ui.panelsArea.AddItem(flexOf(tview.FlexColumn, ui.commandPanels), 0, 1, false)
+	case config.LayoutTabs:
+		ui.panelsArea.AddItem(ui.tabBar(), 1, 0, false)
+		if panel := ui.selectedCommandPanel(); panel != nil {
+			ui.panelsArea.AddItem(panel, 0, 1, false)
+		}
+	case config.LayoutCustom:
+		ui.panelsArea.AddItem(ui.customLayout(), 0, 1, false)
+	default:
+		ui.panelsArea.AddItem(gridOf(ui.commandPanels), 0, 1, false)
+	}
+}
+
+// flexOf puts panels into a Flex of the given direction, equally sized
+func flexOf(direction int, panels []*CommandPanel) *tview.Flex {
+	flex := tview.NewFlex().SetDirection(direction)
+	for _, panel := range panels {
+		flex.AddItem(panel, 0, 1, false)
+	}
+	return flex
+}
+
+// gridOf arranges panels in rows of about the square root of their count,
+// so four panels form a 2x2 grid and five a 3+2 grid
+func gridOf(panels []*CommandPanel) *tview.Flex {
+	columns := int(math.Ceil(math.Sqrt(float64(len(panels)))))
+
+	grid := tview.NewFlex().SetDirection(tview.FlexRow)
+	for start := 0; start < len(panels); start += columns {
+		end := start + columns
+		if end > len(panels) {
+			end = len(panels)
+		}
+		grid.AddItem(flexOf(tview.FlexColumn, panels[start:end]), 0, 1, false)
+	}
+	return grid
+}
+
+// tabBar lists the panels with the selected one highlighted. Clicking a
+// name selects its panel.
+func (ui *TUI) tabBar() *tview.TextView {
+	var b strings.Builder
+	for i, panel := range ui.commandPanels {
+		text, _ := statusText(panel.command.GetStatus())
+		icon, _, _ := strings.Cut(text, " ")
+		fmt.Fprintf(&b, `["tab-%d"] %s %s [""] `, i, icon, tview.Escape(panel.command.Name))
+	}
+
+	bar := tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetWrap(false).
+		SetText(b.String())
+	bar.Highlight(fmt.Sprintf("tab-%d", ui.selectedPanel))
+
+	bar.SetHighlightedFunc(func(added, removed, remaining []string) {
+		var index int
+		if len(added) > 0 {
+			if _, err := fmt.Sscanf(added[0], "tab-%d", &index); err == nil {
+				ui.selectPanel(index)
+			}
+		}
+	})
+	return bar
+}
+
+// customLayout builds the layout tree of the config. Panels the tree does
+// not mention are shown in a row below it.
+func (ui *TUI) customLayout() tview.Primitive {
+	if ui.layoutTree == nil {
+		return gridOf(ui.commandPanels)
+	}
+
+	placed := make(map[*CommandPanel]bool)
+	root := ui.buildLayoutNode(*ui.layoutTree, placed)
+
+	var rest []*CommandPanel
+	for _, panel := range ui.commandPanels {
+		if !placed[panel] {
+			rest = append(rest, panel)
+		}
+	}
+	if len(rest) == 0 && root != nil {
+		return root
+	}
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow)
+	if root != nil {
+		flex.AddItem(root, 0, 3, false)
+	}
+	flex.AddItem(flexOf(tview.FlexColumn, rest), 0, 1, false)
+	return flex
+}
+
+// buildLayoutNode builds the primitive of a layout node, recording the
+// panels it places. It returns nil if the node shows no running command.
+func (ui *TUI) buildLayoutNode(node config.LayoutNode, placed map[*CommandPanel]bool) tview.Primitive {
+	switch {
+	case node.Command != "":
+		for _, panel := range ui.commandPanels {
+			if panel.command.Name == node.Command && !placed[panel] {
+				placed[panel] = true
+				return panel
+			}
+		}
+		return nil
+
+	case node.Set != "":
+		var panels []*CommandPanel
+		for _, panel := range ui.commandPanels {
+			if group := panel.command.Group(); group != nil && group.Key == node.Set && !placed[panel] {
+				placed[panel] = true
+				panels = append(panels, panel)
+			}
+		}
+		if len(panels) == 0 {
+			return nil
+		}
+		return flexOf(tview.FlexRow, panels)
+	}
+
+	direction := tview.FlexRow
+	if node.Direction == config.DirectionRow {
+		direction = tview.FlexColumn
+	}
+	flex := tview.NewFlex().SetDirection(direction)
+	empty := true
+	for _, item := range node.Items {
+		child := ui.buildLayoutNode(item, placed)
+		if child == nil {
+			continue
+		}
+		weight := item.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		flex.AddItem(child, 0, weight, false)
+		empty = false
+	}
+	if empty {
+		return nil
+	}
+	return flex
+}
+
+// cycleLayout switches to the next layout mode
+func (ui *TUI) cycleLayout() {
+	current := 0
+	for i, mode := range config.LayoutModes {
+		if mode == ui.layoutMode {
+			current = i
+		}
+	}
+
+	next := config.LayoutModes[(current+1)%len(config.LayoutModes)]
+	if next == config.LayoutCustom && ui.layoutTree == nil {
+		next = config.LayoutModes[0]
+	}
+	ui.setLayout(next)
+}
+
+// setLayout switches the layout mode and saves it in the config file, so
+// everyone using the file gets the same layout
+func (ui *TUI) setLayout(mode config.LayoutMode) {
+	ui.layoutMode = mode
+	ui.arrangePanels()
+
+	if ui.config == nil || len(ui.config.Files()) == 0 {
+		ui.showMessage(fmt.Sprintf("Layout: %s", mode), tcell.ColorGreen)
+		return
+	}
+	if err := config.SaveLayoutMode(ui.config.Files()[0], mode); err != nil {
+		ui.showMessage(fmt.Sprintf("Layout: %s (not saved: %v)", mode, err), tcell.ColorRed)
+		return
+	}
+	ui.showMessage(fmt.Sprintf("Layout: %s (saved)", mode), tcell.ColorGreen)
+}
+
+// applyLayoutConfig takes the layout mode and tree from a config
+func (ui *TUI) applyLayoutConfig(cfg *config.Config) {
+	ui.layoutTree = nil
+	if cfg.Layout == nil {
+		return
+	}
+	ui.layoutTree = cfg.Layout.Tree
+	if cfg.Layout.Mode != "" {
+		ui.layoutMode = cfg.Layout.Mode
+	}
+}
@@ -0,0 +1,542 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pashkov256/cmdpool/internal/executor"
+	"github.com/rivo/tview"
+)
+
+// historyChunk is the least number of older lines loaded at once when
+// scrolling up past the oldest line of a view
+const historyChunk = 200
+
+// outputLine is a line held by an output view
+type outputLine struct {
+	// seq is the sequence number of the line in its source
+	seq    uint64
+	text   string
+	stream executor.Stream
+	level  executor.Level
+	time   time.Time
+	// label is shown in front of the text, already tagged for colour, and
+	// name is the command the line came from in views of several commands
+	label string
+	name  string
+	// firstMatch is the number of the first search match in the line and
+	// matches how many the line has
+	firstMatch int
+	matches    int
+}
+
+// outputSource provides the lines of an output view, numbered like the
+// output of a command (see executor.Command.OutputSince), and how many
+// lines it keeps
+type outputSource interface {
+	linesSince(seq uint64) (lines []outputLine, next uint64, reset bool)
+	limit() int
+}
+
+// historySource is an output source that can also provide lines it no
+// longer keeps in memory
+type historySource interface {
+	linesBefore(seq uint64, n int) []outputLine
+}
+
+// commandOutput is the output of a single command
+type commandOutput struct {
+	cmd *executor.Command
+}
+
+// linesSince returns the lines of the command added after seq
+func (c commandOutput) linesSince(seq uint64) ([]outputLine, uint64, bool) {
+	lines, next, reset := c.cmd.OutputSince(seq)
+	return toOutputLines(lines), next, reset
+}
+
+// limit returns how many lines the command keeps in memory
+func (c commandOutput) limit() int {
+	return c.cmd.MaxOutputLines()
+}
+
+// linesBefore returns up to n lines of the command older than seq, read
+// back from its spilled output
+func (c commandOutput) linesBefore(seq uint64, n int) []outputLine {
+	// Lines that cannot be read back are left out like evicted ones
+	lines, _ := c.cmd.OutputBefore(seq, n)
+	return toOutputLines(lines)
+}
+
+// toOutputLines converts the output lines of a command
+func toOutputLines(lines []executor.OutputLine) []outputLine {
+	result := make([]outputLine, len(lines))
+	for i, line := range lines {
+		result[i] = outputLine{seq: line.Seq, text: line.Text, stream: line.Stream, level: line.Level, time: line.Time}
+	}
+	return result
+}
+
+// lineFilter selects the lines a view shows by stream and level
+type lineFilter struct {
+	// stream is the only stream shown, or empty to show all of them
+	stream executor.Stream
+	// level is the lowest level shown, or LevelNone to show every line
+	level executor.Level
+}
+
+// keeps reports whether a line passes the filter. Messages of cmdpool are
+// shown with every stream and level.
+func (f lineFilter) keeps(line outputLine) bool {
+	if line.stream == executor.StreamSystem {
+		return true
+	}
+	if f.stream != "" && line.stream != f.stream {
+		return false
+	}
+	return line.level >= f.level
+}
+
+// title returns what the filter adds to the title of a view
+func (f lineFilter) title() string {
+	var title string
+	if f.stream != "" {
+		title += fmt.Sprintf(" | %s only", f.stream)
+	}
+	if f.level != executor.LevelNone {
+		title += fmt.Sprintf(" | %s+", strings.ToLower(f.level.String()))
+	}
+	return title
+}
+
+// nextStream returns the stream shown after stream when switching streams:
+// all of them (empty), stdout, stderr and all again
+func nextStream(stream executor.Stream) executor.Stream {
+	switch stream {
+	case "":
+		return executor.StreamStdout
+	case executor.StreamStdout:
+		return executor.StreamStderr
+	}
+	return ""
+}
+
+// nextLevel returns the lowest level shown after level when switching
+// levels: every line, info and above, warn and above, errors and every
+// line again
+func nextLevel(level executor.Level) executor.Level {
+	switch level {
+	case executor.LevelNone:
+		return executor.LevelInfo
+	case executor.LevelInfo:
+		return executor.LevelWarn
+	case executor.LevelWarn:
+		return executor.LevelError
+	}
+	return executor.LevelNone
+}
+
+// levelColors are the colours of the level in front of log lines
+var levelColors = map[executor.Level]string{
+	executor.LevelDebug: "gray",
+	executor.LevelInfo:  "aqua",
+	executor.LevelWarn:  "yellow",
+	executor.LevelError: "red",
+}
+
+// outputView shows the output of a command, or of several merged. Each update appends only the
+// lines added since the previous one, and drawing only formats the lines
+// that are visible, so chatty commands stay cheap to show. Everything is
+// rendered again only when the output was cleared or the search changed.
+type outputView struct {
+	*tview.Box
+	lines []outputLine
+	// source is where the lines were last taken from and seq the sequence
+	// number of the newest line taken
+	source outputSource
+	seq    uint64
+	// history is the number of older lines loaded while scrolling up,
+	// which are kept beyond the limit of the source until following again
+	history int
+	// search and filter are the search state the lines were taken with,
+	// and show the lines they were restricted to
+	search *outputSearch
+	filter bool
+	show   lineFilter
+	// stale forces the next update to take all lines again
+	stale bool
+	// follow keeps the newest line in view; otherwise top is the index
+	// of the first line shown
+	follow bool
+	top    int
+	// height is the number of rows available at the last draw and shown
+	// the number of lines drawn from top on
+	height    int
+	shown     int
+	textColor tcell.Color
+	// timestamps is how line times are shown, relative to started
+	timestamps timestampMode
+	started    time.Time
+}
+
+// newOutputView creates an empty output view following the newest line
+func newOutputView() *outputView {
+	return &outputView{
+		Box:       tview.NewBox(),
+		stale:     true,
+		follow:    true,
+		textColor: tview.Styles.PrimaryTextColor,
+	}
+}
+
+// SetTextColor sets the colour of the output text
+func (o *outputView) SetTextColor(color tcell.Color) *outputView {
+	o.textColor = color
+	return o
+}
+
+// invalidate makes the next update take all lines again
+func (o *outputView) invalidate() {
+	o.stale = true
+}
+
+// following reports whether the view keeps the newest line in view
+func (o *outputView) following() bool {
+	return o.follow
+}
+
+// setFollow starts or stops following the newest line. Stopping keeps the
+// lines currently shown in view; following drops the history loaded.
+func (o *outputView) setFollow(follow bool) {
+	o.follow = follow
+	if follow && o.history > 0 {
+		o.history = 0
+		o.trim()
+	}
+}
+
+// update brings the view up to date with its source, showing only the
+// lines that pass show. It reports whether anything changed and whether
+// the view scrolled to the selected search match.
+func (o *outputView) update(source outputSource, search *outputSearch, show lineFilter) (changed, jumped bool) {
+	full := o.stale || search != o.search || (search != nil && search.filter != o.filter) || show != o.show
+
+	since := o.seq
+	if full {
+		since = 0
+	}
+	lines, next, reset := source.linesSince(since)
+	full = full || reset
+
+	jump := search.active() && search.jump
+	if !full && len(lines) == 0 && !jump {
+		return false, false
+	}
+
+	o.source = source
+	if full {
+		o.lines = o.lines[:0]
+		o.top = 0
+		o.history = 0
+		o.search = search
+		o.filter = search != nil && search.filter
+		o.show = show
+		o.stale = false
+		if search != nil {
+			search.matches = 0
+		}
+	}
+	o.seq = next
+	o.append(lines, search)
+
+	if jump {
+		o.scrollToMatch(search)
+		search.jump = false
+	}
+	return true, jump
+}
+
+// append adds the lines shown, counting their search matches,
+// and drops the oldest lines beyond the limit of the source
+func (o *outputView) append(lines []outputLine, s *outputSearch) {
+	first := 0
+	if s.active() {
+		first = s.matches
+	}
+	kept, matches := o.keep(lines, s, first)
+	o.lines = append(o.lines, kept...)
+	if s.active() {
+		s.matches += matches
+	}
+	o.trim()
+}
+
+// keep returns the lines shown that pass the search filter, numbering
+// their search matches from first, and the number of matches
+func (o *outputView) keep(lines []outputLine, s *outputSearch, first int) ([]outputLine, int) {
+	kept := lines[:0]
+	matches := 0
+	for _, line := range lines {
+		if !o.show.keeps(line) {
+			continue
+		}
+		if s.active() {
+			line.firstMatch = first + matches
+			line.matches = countMatches(s.re, line.text)
+			matches += line.matches
+			if s.filter && line.matches == 0 {
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+	return kept, matches
+}
+
+// trim drops the oldest lines beyond the limit of the source and the
+// history loaded
+func (o *outputView) trim() {
+	limit := executor.DefaultMaxOutputLines
+	if o.source != nil {
+		limit = o.source.limit()
+	}
+	if extra := len(o.lines) - limit - o.history; extra > 0 {
+		n := copy(o.lines, o.lines[extra:])
+		o.lines = o.lines[:n]
+		o.top = max(o.top-extra, 0)
+	}
+
+	// Matches in dropped lines can no longer be selected
+	s := o.search
+	if s.active() {
+		s.first = s.matches
+		if len(o.lines) > 0 {
+			s.first = o.lines[0].firstMatch
+		}
+		if s.current < s.first || s.current >= s.matches {
+			s.current = s.first
+		}
+	}
+}
+
+// scrollToMatch stops following and centres the line of the selected match
+func (o *outputView) scrollToMatch(s *outputSearch) {
+	index := sort.Search(len(o.lines), func(i int) bool {
+		return o.lines[i].firstMatch+o.lines[i].matches > s.current
+	})
+	if index == len(o.lines) {
+		return
+	}
+	o.follow = false
+	o.top = max(index-o.height/2, 0)
+}
+
+// loadHistory puts up to n older lines in front of the view if its source
+// still has them, and returns the number of lines added
+func (o *outputView) loadHistory(n int) int {
+	history, ok := o.source.(historySource)
+	if !ok {
+		return 0
+	}
+	before := o.seq + 1
+	if len(o.lines) > 0 {
+		before = o.lines[0].seq
+	}
+
+	// Lines left out by the line or search filter do not count
+	var added []outputLine
+	matches := 0
+	for len(added) == 0 {
+		lines := history.linesBefore(before, max(n, historyChunk))
+		if len(lines) == 0 {
+			return 0
+		}
+		before = lines[0].seq
+		kept, count := o.keep(lines, o.search, 0)
+		added = append(kept, added...)
+		matches += count
+	}
+
+	// Number the new matches before the ones shown, moving those up if
+	// there is no room
+	s := o.search
+	if s.active() {
+		added = o.numberHistory(added, matches)
+	}
+
+	o.lines = append(added, o.lines...)
+	o.history += len(added)
+	o.top += len(added)
+	return len(added)
+}
+
+// numberHistory renumbers the search matches of lines loaded from history,
+// counted from 0, so they come before the matches of the lines shown
+func (o *outputView) numberHistory(lines []outputLine, matches int) []outputLine {
+	s := o.search
+	if shift := matches - s.first; shift > 0 {
+		for i := range o.lines {
+			o.lines[i].firstMatch += shift
+		}
+		s.matches += shift
+		s.current += shift
+		s.first += shift
+	}
+
+	base := s.first - matches
+	for i := range lines {
+		lines[i].firstMatch += base
+	}
+	s.first = base
+	return lines
+}
+
+// scroll moves the view by delta lines, loading older lines when moving
+// past the oldest one. Scrolling down to the newest line follows it again.
+func (o *outputView) scroll(delta int) {
+	o.follow = false
+	if o.top+delta < 0 {
+		o.top += o.loadHistory(-(o.top + delta))
+	}
+	o.top = min(max(o.top+delta, 0), max(len(o.lines)-1, 0))
+	if delta > 0 && o.top >= len(o.lines)-o.height {
+		o.setFollow(true)
+	}
+}
+
+// visibleLines returns the lines shown at the last draw, including ones
+// only partly in view
+func (o *outputView) visibleLines() []outputLine {
+	start := min(o.top, len(o.lines))
+	return o.lines[start:min(start+o.shown, len(o.lines))]
+}
+
+// page returns the number of lines scrolled by PgUp and PgDn
+func (o *outputView) page() int {
+	return max(o.height-1, 1)
+}
+
+// scrollToTop stops following and shows the oldest line
+func (o *outputView) scrollToTop() {
+	o.follow = false
+	o.top = 0
+}
+
+// MouseHandler scrolls with the mouse wheel and takes the focus on click
+func (o *outputView) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return o.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		if !o.InRect(event.Position()) {
+			return false, nil
+		}
+
+		switch action {
+		case tview.MouseLeftDown:
+			setFocus(o)
+		case tview.MouseScrollUp:
+			o.scroll(-1)
+		case tview.MouseScrollDown:
+			o.scroll(1)
+		default:
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+// Draw draws the visible lines, wrapping long ones
+func (o *outputView) Draw(screen tcell.Screen) {
+	o.Box.DrawForSubclass(screen, o)
+
+	x, y, width, height := o.GetInnerRect()
+	o.height = height
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	var rows []outputRow
+	if o.follow {
+		// Collect rows from the newest line upwards until the view is full
+		var chunks [][]outputRow
+		count := 0
+		o.top = len(o.lines)
+		for i := len(o.lines) - 1; i >= 0 && count < height; i-- {
+			wrapped := o.wrap(i, width)
+			chunks = append(chunks, wrapped)
+			count += len(wrapped)
+			o.top = i
+		}
+		for i := len(chunks) - 1; i >= 0; i-- {
+			rows = append(rows, chunks[i]...)
+		}
+		if len(rows) > height {
+			rows = rows[len(rows)-height:]
+		}
+		o.shown = len(o.lines) - o.top
+	} else {
+		o.top = min(o.top, max(len(o.lines)-1, 0))
+		o.shown = 0
+		for i := o.top; i < len(o.lines) && len(rows) < height; i++ {
+			rows = append(rows, o.wrap(i, width)...)
+			o.shown++
+		}
+		if len(rows) > height {
+			rows = rows[:height]
+		}
+	}
+
+	for i, row := range rows {
+		tview.Print(screen, row.text, x, y+i, width, tview.AlignLeft, row.color)
+	}
+}
+
+// outputRow is a screen row of a wrapped line
+type outputRow struct {
+	text  string
+	color tcell.Color
+}
+
+// wrap formats a line and splits it into rows of at most width cells
+func (o *outputView) wrap(index, width int) []outputRow {
+	text := o.format(index)
+	color := o.lineColor(o.lines[index])
+	if tview.TaggedStringWidth(text) <= width {
+		return []outputRow{{text, color}}
+	}
+
+	wrapped := tview.WordWrap(text, width)
+	rows := make([]outputRow, len(wrapped))
+	for i, row := range wrapped {
+		rows[i] = outputRow{row, color}
+	}
+	return rows
+}
+
+// lineColor returns the colour of a line: stderr and the messages of
+// cmdpool stand out from regular output
+func (o *outputView) lineColor(line outputLine) tcell.Color {
+	switch line.stream {
+	case executor.StreamStderr:
+		return tcell.ColorRed
+	case executor.StreamSystem:
+		return tcell.ColorGray
+	}
+	return o.textColor
+}
+
+// format escapes a line for printing, highlights its search matches and
+// puts its time in front if timestamps are shown, and its level if it has
+// one
+func (o *outputView) format(index int) string {
+	line := o.lines[index]
+	prefix := o.gutter(index) + line.label
+	if line.level != executor.LevelNone {
+		prefix += fmt.Sprintf("[%s]%-5s[-] ", levelColors[line.level], line.level)
+	}
+	if !o.search.active() || line.matches == 0 {
+		return prefix + tview.Escape(line.text)
+	}
+	return prefix + highlightMatches(o.search.re, line.text, line.firstMatch, o.search.current)
+}
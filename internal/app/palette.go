@@ -0,0 +1,353 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pashkov256/cmdpool/internal/config"
+	"github.com/pashkov256/cmdpool/internal/executor"
+	"github.com/rivo/tview"
+)
+
+// palettePage is the name of the page showing the command palette
+const palettePage = "palette"
+
+// paletteEntry is an action offered by the command palette
+type paletteEntry struct {
+	title string
+	// key is the key bound to the action, if any
+	key string
+	run func()
+}
+
+// showPalette lets the user find an action or command by typing part of
+// its name and runs the chosen one
+func (ui *TUI) showPalette() {
+	entries := ui.paletteEntries()
+
+	input := tview.NewInputField().
+		SetLabel("> ").
+		SetFieldBackgroundColor(tcell.ColorDefault)
+	list := tview.NewList().
+		ShowSecondaryText(false).
+		SetHighlightFullLine(true)
+
+	var shown []paletteEntry
+	refresh := func(query string) {
+		list.Clear()
+		shown = filterPalette(entries, query)
+		for _, entry := range shown {
+			text := tview.Escape(entry.title)
+			if entry.key != "" {
+				text += fmt.Sprintf("  [gray]%s[-]", tview.Escape(displayKey(entry.key)))
+			}
+			list.AddItem(text, "", 0, nil)
+		}
+	}
+	run := func() {
+		index := list.GetCurrentItem()
+		if index < 0 || index >= len(shown) {
+			return
+		}
+		ui.closeDialog()
+		shown[index].run()
+		ui.updateUI()
+	}
+
+	input.SetChangedFunc(refresh)
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			run()
+			return
+		}
+		ui.closeDialog()
+	})
+	// The selection moves while typing goes on in the input
+	input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		count := list.GetItemCount()
+		if count == 0 {
+			return event
+		}
+		switch event.Key() {
+		case tcell.KeyUp, tcell.KeyCtrlP:
+			list.SetCurrentItem((list.GetCurrentItem() - 1 + count) % count)
+		case tcell.KeyDown, tcell.KeyCtrlN:
+			list.SetCurrentItem((list.GetCurrentItem() + 1) % count)
+		default:
+			return event
+		}
+		return nil
+	})
+	list.SetSelectedFunc(func(int, string, string, rune) {
+		run()
+	})
+	refresh("")
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(input, 1, 0, true).
+		AddItem(list, 0, 1, false)
+	layout.SetBorder(true).SetTitle(" Commands ")
+
+	ui.showDialog(palettePage, layout, 72, 20)
+}
+
+// paletteEntries lists the actions of the palette for the current state:
+// the commands by name and what can be done with them, the command sets of
+// the config and the actions of the interface
+func (ui *TUI) paletteEntries() []paletteEntry {
+	context := config.KeyContextList
+	switch {
+	case ui.timeline != nil:
+		context = config.KeyContextTimeline
+	case ui.zoom != nil:
+		context = config.KeyContextZoom
+	}
+	action := func(title, action string, run func()) paletteEntry {
+		entry := paletteEntry{title: title, run: run}
+		if keys := ui.keys.keys(context, action); len(keys) > 0 {
+			entry.key = keys[0]
+		}
+		return entry
+	}
+
+	var entries []paletteEntry
+
+	// Commands
+	alerted := false
+	for i, panel := range ui.commandPanels {
+		index, cmd := i, panel.command
+		entries = append(entries, paletteEntry{title: "Show " + cmd.Name, run: func() { ui.showPanel(index) }})
+		if cmd.GetStatus().Finished() {
+			entries = append(entries, paletteEntry{title: "Start " + cmd.Name, run: func() { ui.restartCommand(cmd) }})
+		} else {
+			entries = append(entries,
+				paletteEntry{title: "Restart " + cmd.Name, run: func() { ui.restartCommand(cmd) }},
+				paletteEntry{title: "Stop " + cmd.Name, run: func() { ui.stopCommand(cmd) }})
+		}
+		if count, _, _ := cmd.AlertState(); count > 0 {
+			entries = append(entries, paletteEntry{title: "Clear alerts of " + cmd.Name, run: func() { ui.ackAlerts(cmd) }})
+			alerted = true
+		}
+	}
+	if len(ui.commandPanels) > 0 {
+		entries = append(entries,
+			paletteEntry{title: "Restart all", run: ui.restartAll},
+			paletteEntry{title: "Stop all", run: ui.stopAll})
+	}
+	if alerted {
+		entries = append(entries, paletteEntry{title: "Clear all alerts", run: ui.ackAllAlerts})
+	}
+
+	// Command sets
+	if ui.config != nil {
+		active := make(map[string]bool)
+		for _, group := range ui.executor.GetGroups() {
+			active[group.Key] = group.Active()
+		}
+		for _, key := range ui.config.SetNames() {
+			key := key
+			if active[key] {
+				entries = append(entries, paletteEntry{title: "Restart set " + setName(ui.config, key), run: func() { ui.startSet(key) }})
+			} else {
+				entries = append(entries, paletteEntry{title: "Start set " + setName(ui.config, key), run: func() { ui.startSet(key) }})
+			}
+		}
+	}
+
+	// Interface
+	for _, mode := range config.LayoutModes {
+		mode := mode
+		if mode == ui.layoutMode || (mode == config.LayoutCustom && ui.layoutTree == nil) {
+			continue
+		}
+		entries = append(entries, paletteEntry{title: fmt.Sprintf("Layout: %s", mode), run: func() { ui.setLayout(mode) }})
+	}
+	entries = append(entries, action("Switch layout", config.ActionLayout, ui.cycleLayout))
+	if target := ui.searchTarget(); target != nil {
+		entries = append(entries, action("Search", config.ActionSearch, func() { ui.startSearch(false) }))
+		if target.search.active() {
+			entries = append(entries,
+				action("Toggle filter", config.ActionFilter, func() { ui.withSearch((*searchable).toggleFilter) }),
+				paletteEntry{title: "Clear search", run: func() { ui.withSearch((*searchable).clearSearch) }})
+		}
+		entries = append(entries,
+			action("Switch streams (all, stdout, stderr)", config.ActionStreams, func() { ui.runAction(context, config.ActionStreams) }),
+			action("Switch log levels (all, info+, warn+, error)", config.ActionLevels, func() { ui.runAction(context, config.ActionLevels) }))
+	}
+	if ui.timeline == nil && ui.selectedCommandPanel() != nil {
+		entries = append(entries, action("Save output to file", config.ActionSave, ui.saveOutput))
+	}
+	entries = append(entries,
+		action("Copy lines in view", config.ActionCopy, ui.copyVisible),
+		action("Export log archive of all commands", config.ActionExport, ui.exportArchive),
+		action("Search all panels", config.ActionSearchAll, func() { ui.startSearch(true) }),
+		action("Toggle timestamps", config.ActionTimestamps, ui.cycleTimestamps))
+	if ui.zoom != nil {
+		entries = append(entries,
+			action("Toggle follow", config.ActionFollow, ui.zoom.toggleFollow),
+			action("Back to all panels", config.ActionClose, ui.closeZoom))
+	}
+	if ui.timeline != nil {
+		entries = append(entries,
+			action("Freeze timeline", config.ActionFreeze, ui.timeline.toggleFreeze),
+			action("Choose timeline commands", config.ActionSelect, ui.selectTimelineCommands),
+			action("Back to all panels", config.ActionClose, ui.closeTimeline))
+	} else {
+		entries = append(entries, action("Show timeline", config.ActionTimeline, func() { ui.openTimeline(nil) }))
+	}
+	entries = append(entries,
+		action("Add command", config.ActionAdd, ui.addNewCommand),
+		action("Show key bindings", config.ActionHelp, func() { ui.showHelp(context) }),
+		action("Quit", config.ActionQuit, ui.quit))
+
+	return entries
+}
+
+// setName returns the display name of a command set
+func setName(cfg *config.Config, key string) string {
+	if name := cfg.CommandSets[key].Name; name != "" && name != key {
+		return fmt.Sprintf("%s (%s)", key, name)
+	}
+	return key
+}
+
+// showPanel selects a panel, showing it full screen if a panel is
+func (ui *TUI) showPanel(index int) {
+	ui.closeTimeline()
+	ui.selectPanel(index)
+	if ui.zoom != nil && ui.zoom.panel != ui.commandPanels[index] {
+		ui.closeZoom()
+		ui.openZoom(ui.commandPanels[index])
+	}
+}
+
+// restartCommand restarts a command, or starts a finished one again. It
+// runs in the background, as stopping waits for the stop hooks.
+func (ui *TUI) restartCommand(cmd *executor.Command) {
+	go func() {
+		if err := ui.executor.RestartCommand(cmd.ID); err != nil {
+			ui.app.QueueUpdateDraw(func() {
+				ui.showMessage(fmt.Sprintf("Error restarting command: %v", err), tcell.ColorRed)
+			})
+		}
+	}()
+}
+
+// stopCommand stops a command in the background, as stopping waits for
+// the stop hooks
+func (ui *TUI) stopCommand(cmd *executor.Command) {
+	go func() {
+		if err := ui.executor.StopCommand(cmd.ID); err != nil {
+			ui.app.QueueUpdateDraw(func() {
+				ui.showMessage(fmt.Sprintf("Error stopping command: %v", err), tcell.ColorRed)
+			})
+		}
+	}()
+}
+
+// restartAll restarts every command in the background
+func (ui *TUI) restartAll() {
+	cmds := ui.executor.List()
+	go func() {
+		for _, cmd := range cmds {
+			ui.executor.RestartCommand(cmd.ID)
+		}
+	}()
+	ui.showMessage(fmt.Sprintf("Restarting %d commands", len(cmds)), tcell.ColorGreen)
+}
+
+// stopAll stops every command in the background, leaving cmdpool running
+func (ui *TUI) stopAll() {
+	cmds := ui.executor.List()
+	go func() {
+		for _, cmd := range cmds {
+			ui.executor.StopCommand(cmd.ID)
+		}
+	}()
+	ui.showMessage(fmt.Sprintf("Stopping %d commands", len(cmds)), tcell.ColorYellow)
+}
+
+// startSet starts a command set of the config, replacing the commands of
+// an earlier run of the set
+func (ui *TUI) startSet(key string) {
+	set, exists := ui.config.CommandSets[key]
+	if !exists {
+		return
+	}
+	if ui.zoom != nil {
+		if group := ui.zoom.panel.command.Group(); group != nil && group.Key == key {
+			ui.closeZoom()
+		}
+	}
+
+	// Stopping the earlier run waits for its stop hooks
+	name := setName(ui.config, key)
+	ui.showMessage("Starting set "+name, tcell.ColorYellow)
+	go func() {
+		ui.executor.RestartSet(key, set)
+		ui.app.QueueUpdateDraw(func() {
+			ui.syncPanels()
+			ui.showMessage("Started set "+name, tcell.ColorGreen)
+		})
+	}()
+}
+
+// filterPalette returns the entries matching a query, best matches first.
+// Entries keep their order when they match equally well.
+func filterPalette(entries []paletteEntry, query string) []paletteEntry {
+	type scored struct {
+		entry paletteEntry
+		score int
+	}
+
+	var matches []scored
+	for _, entry := range entries {
+		if score, ok := fuzzyScore(query, entry.title); ok {
+			matches = append(matches, scored{entry, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	result := make([]paletteEntry, len(matches))
+	for i, match := range matches {
+		result[i] = match.entry
+	}
+	return result
+}
+
+// fuzzyScore reports whether the characters of query appear in text in
+// order, ignoring case and spaces, and scores the match. Characters at the
+// start of a word and runs of consecutive characters score higher.
+func fuzzyScore(query, text string) (int, bool) {
+	target := []rune(strings.ToLower(text))
+	score := 0
+	pos := 0
+	previous := -2
+	for _, r := range strings.ToLower(query) {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		for pos < len(target) && target[pos] != r {
+			pos++
+		}
+		if pos == len(target) {
+			return 0, false
+		}
+
+		score++
+		if pos == previous+1 {
+			score += 5
+		}
+		if pos == 0 || !unicode.IsLetter(target[pos-1]) && !unicode.IsDigit(target[pos-1]) {
+			score += 3
+		}
+		previous = pos
+		pos++
+	}
+	return score, true
+}
@@ -0,0 +1,238 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pashkov256/cmdpool/internal/config"
+	"github.com/pashkov256/cmdpool/internal/executor"
+	"github.com/rivo/tview"
+)
+
+// CommandPanel represents a single command display panel. It draws a
+// bordered box with a one line header (status, pid, uptime, restarts)
+// above the output of the command.
+type CommandPanel struct {
+	*tview.Box
+	command *executor.Command
+	header  *tview.TextView
+	// shownHeader is the text of the header as last rendered
+	shownHeader string
+	output      *outputView
+	searchable
+	// show selects the lines shown
+	show     lineFilter
+	selected func(panel *CommandPanel)
+	// alertSeq is the newest alert of the command notified of. The border
+	// blinks in flashColor until flashUntil; flashShown is whether it was
+	// last drawn in it.
+	alertSeq   uint64
+	flashColor tcell.Color
+	flashUntil time.Time
+	flashShown bool
+}
+
+// NewCommandPanel creates a new command panel
+func NewCommandPanel(command *executor.Command) *CommandPanel {
+	panel := &CommandPanel{
+		Box:     tview.NewBox().SetBorder(true),
+		command: command,
+	}
+	// Alerts raised before the panel existed are not notified again
+	_, _, panel.alertSeq = command.AlertState()
+
+	// Create header
+	panel.header = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+
+	// Create output
+	panel.output = newOutputView()
+	panel.output.SetTextColor(tcell.ColorGreen)
+
+	// Set up layout
+	panel.SetTitle(panel.titleText())
+	if command.Color != "" {
+		panel.SetTitleColor(tcell.GetColor(command.Color))
+	}
+	panel.updateDisplay()
+
+	return panel
+}
+
+// SetSelectedFunc sets a handler called when the panel is clicked
+func (panel *CommandPanel) SetSelectedFunc(handler func(panel *CommandPanel)) *CommandPanel {
+	panel.selected = handler
+	return panel
+}
+
+// Draw draws the border, the header and the output
+func (panel *CommandPanel) Draw(screen tcell.Screen) {
+	if panel.flashShown {
+		border := panel.GetBorderColor()
+		panel.SetBorderColor(panel.flashColor)
+		panel.Box.DrawForSubclass(screen, panel)
+		panel.SetBorderColor(border)
+	} else {
+		panel.Box.DrawForSubclass(screen, panel)
+	}
+
+	x, y, width, height := panel.GetInnerRect()
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	panel.header.SetRect(x, y, width, 1)
+	panel.header.Draw(screen)
+
+	// Tiny panels only show the header
+	if height > 1 {
+		panel.output.SetRect(x, y+1, width, height-1)
+		panel.output.Draw(screen)
+	}
+}
+
+// Focus passes the focus on to the output so it can be scrolled
+func (panel *CommandPanel) Focus(delegate func(p tview.Primitive)) {
+	delegate(panel.output)
+}
+
+// HasFocus reports whether the output has the focus
+func (panel *CommandPanel) HasFocus() bool {
+	return panel.output.HasFocus()
+}
+
+// MouseHandler selects the panel on click and scrolls the output with the
+// mouse wheel
+func (panel *CommandPanel) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return panel.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		if !panel.InRect(event.Position()) {
+			return false, nil
+		}
+
+		switch action {
+		case tview.MouseLeftDown:
+			setFocus(panel)
+			if panel.selected != nil {
+				panel.selected(panel)
+			}
+			return true, nil
+		case tview.MouseScrollUp, tview.MouseScrollDown:
+			return panel.output.MouseHandler()(action, event, setFocus)
+		}
+
+		// Clicks on the border are swallowed too
+		return true, nil
+	})
+}
+
+// updateDisplay updates the panel display and reports whether anything
+// changed, so unchanged panels do not cause a redraw
+func (panel *CommandPanel) updateDisplay() bool {
+	// Update output, highlighting search matches
+	changed, _ := panel.output.update(commandOutput{panel.command}, panel.search, panel.show)
+
+	if header := panel.headerText(); header != panel.shownHeader {
+		panel.shownHeader = header
+		panel.header.SetText(header)
+		changed = true
+	}
+	if title := panel.titleText(); title != panel.GetTitle() {
+		panel.SetTitle(title)
+		changed = true
+	}
+	if flashing := panel.flashing(); flashing != panel.flashShown {
+		panel.flashShown = flashing
+		changed = true
+	}
+	return changed
+}
+
+// cycleStream switches between showing all output, only stdout and only
+// stderr
+func (panel *CommandPanel) cycleStream() {
+	panel.show.stream = nextStream(panel.show.stream)
+}
+
+// cycleLevel switches the lowest level of the log lines shown
+func (panel *CommandPanel) cycleLevel() {
+	panel.show.level = nextLevel(panel.show.level)
+}
+
+// headerText returns the status line of the panel: the status, the pid
+// and uptime of a running process and the number of restarts
+func (panel *CommandPanel) headerText() string {
+	status := panel.command.GetStatus()
+	text, color := statusText(status)
+	parts := []string{fmt.Sprintf("[#%06x]%s[-]", color.Hex(), tview.Escape(text))}
+
+	pid, startTime, endTime, restarts := panel.command.GetRuntime()
+	switch {
+	case status == executor.StatusRunning:
+		if pid != 0 {
+			parts = append(parts, fmt.Sprintf("pid %d", pid))
+		}
+		parts = append(parts, "up "+formatDuration(time.Since(startTime)))
+	case status.Finished() && !endTime.IsZero():
+		parts = append(parts, "ran "+formatDuration(endTime.Sub(startTime)))
+	}
+	if restarts > 0 {
+		parts = append(parts, fmt.Sprintf("restarts %d", restarts))
+	}
+
+	return strings.Join(parts, " | ")
+}
+
+// formatDuration rounds a duration to whole seconds for display
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return d.Round(time.Second).String()
+}
+
+// statusText returns the label and colour shown for a status
+func statusText(status executor.CommandStatus) (string, tcell.Color) {
+	switch status {
+	case executor.StatusRunning:
+		return "🟢 Running", tcell.ColorGreen
+	case executor.StatusDone:
+		return "✅ Done", tcell.ColorGreen
+	case executor.StatusFailed:
+		return "🔴 Failed", tcell.ColorRed
+	case executor.StatusStopped:
+		return "⏹️ Stopped", tcell.ColorYellow
+	case executor.StatusSkipped:
+		return "⏭️ Skipped", tcell.ColorGray
+	case executor.StatusScheduled:
+		return "⏰ Scheduled", tcell.ColorBlue
+	}
+	return string(status), tcell.ColorWhite
+}
+
+// titleText returns the panel title, including the step of sequential
+// sets and the last result and next fire time of scheduled commands
+func (panel *CommandPanel) titleText() string {
+	title := panel.command.Name
+
+	group := panel.command.Group()
+	if group != nil && group.Mode == config.ModeSequential {
+		title += fmt.Sprintf(" (%d/%d)", panel.command.Step(), len(group.List()))
+	}
+
+	if lastRun, lastResult, nextRun := panel.command.GetSchedule(); !nextRun.IsZero() {
+		if !lastRun.IsZero() {
+			title += fmt.Sprintf(" | last %s %s", lastRun.Format("15:04:05"), lastResult)
+		}
+		title += fmt.Sprintf(" | next %s", nextRun.Format("15:04:05"))
+	}
+	title += panel.show.title()
+	title += panel.search.status()
+	if badge := panel.alertBadge(); badge != "" {
+		title += " " + badge
+	}
+
+	return fmt.Sprintf(" %s ", title)
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pashkov256/cmdpool/internal/config"
+	"github.com/pashkov256/cmdpool/internal/executor"
+	"github.com/rivo/tview"
+)
+
+// runCommand runs a command to completion and returns it
+func runCommand(t *testing.T, name, run string) *executor.Command {
+	t.Helper()
+	exec := executor.NewExecutor()
+	cmd := exec.Start(config.CommandEntry{Name: name, Run: run, Dir: "."})
+	cmd.Wait()
+	return cmd
+}
+
+// newScreen returns an initialised simulation screen of the given size
+func newScreen(t *testing.T, width, height int) tcell.SimulationScreen {
+	t.Helper()
+	screen := tcell.NewSimulationScreen("UTF-8")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(width, height)
+	t.Cleanup(screen.Fini)
+	return screen
+}
+
+// drawPanel draws a panel filling the screen and returns its rows
+func drawPanel(screen tcell.SimulationScreen, panel *CommandPanel) []string {
+	width, height := screen.Size()
+	panel.SetRect(0, 0, width, height)
+	panel.Draw(screen)
+	screen.Show()
+
+	cells, width, height := screen.GetContents()
+	rows := make([]string, height)
+	for y := 0; y < height; y++ {
+		var row strings.Builder
+		for x := 0; x < width; x++ {
+			runes := cells[y*width+x].Runes
+			if len(runes) == 0 {
+				row.WriteRune(' ')
+				continue
+			}
+			row.WriteString(string(runes))
+		}
+		rows[y] = row.String()
+	}
+	return rows
+}
+
+func TestCommandPanelDrawsHeaderAndOutput(t *testing.T) {
+	cmd := runCommand(t, "greeter", "echo hello world")
+	panel := NewCommandPanel(cmd)
+	panel.updateDisplay()
+
+	rows := drawPanel(newScreen(t, 40, 6), panel)
+
+	if !strings.Contains(rows[0], "greeter") {
+		t.Errorf("title row = %q, want the command name", rows[0])
+	}
+	if !strings.Contains(rows[1], "Done") || !strings.Contains(rows[1], "ran ") {
+		t.Errorf("header row = %q, want the status and run time", rows[1])
+	}
+	if !strings.Contains(rows[2], "hello world") {
+		t.Errorf("output row = %q, want the command output", rows[2])
+	}
+	if !strings.HasPrefix(rows[5], "└") {
+		t.Errorf("bottom row = %q, want the border", rows[5])
+	}
+}
+
+func TestCommandPanelHeaderShowsFailure(t *testing.T) {
+	cmd := runCommand(t, "broken", `sh -c "exit 3"`)
+	panel := NewCommandPanel(cmd)
+
+	rows := drawPanel(newScreen(t, 40, 5), panel)
+
+	if !strings.Contains(rows[1], "Failed") {
+		t.Errorf("header row = %q, want the failed status", rows[1])
+	}
+}
+
+func TestCommandPanelTinyRect(t *testing.T) {
+	cmd := runCommand(t, "tiny", "echo hidden")
+	panel := NewCommandPanel(cmd)
+
+	// Only the border and the header fit
+	rows := drawPanel(newScreen(t, 20, 3), panel)
+	for _, row := range rows {
+		if strings.Contains(row, "hidden") {
+			t.Errorf("output drawn outside the panel: %q", rows)
+		}
+	}
+
+	// Nothing fits inside the border
+	drawPanel(newScreen(t, 2, 2), panel)
+}
+
+func TestCommandPanelFocusDelegatesToOutput(t *testing.T) {
+	panel := NewCommandPanel(runCommand(t, "focus", "echo hi"))
+
+	var focused tview.Primitive
+	panel.Focus(func(p tview.Primitive) {
+		focused = p
+		p.Focus(nil)
+	})
+
+	if focused != panel.output {
+		t.Fatalf("focus delegated to %T, want the output view", focused)
+	}
+	if !panel.HasFocus() {
+		t.Error("HasFocus() = false after focusing the output")
+	}
+}
+
+func TestCommandPanelMouseClickSelects(t *testing.T) {
+	panel := NewCommandPanel(runCommand(t, "click", "echo hi"))
+	panel.SetRect(10, 5, 20, 6)
+
+	var selected *CommandPanel
+	panel.SetSelectedFunc(func(p *CommandPanel) { selected = p })
+
+	var focused tview.Primitive
+	setFocus := func(p tview.Primitive) { focused = p }
+	handler := panel.MouseHandler()
+
+	// Clicks outside the panel are ignored
+	outside := tcell.NewEventMouse(2, 2, tcell.Button1, 0)
+	if consumed, _ := handler(tview.MouseLeftDown, outside, setFocus); consumed || selected != nil {
+		t.Fatal("click outside the panel was handled")
+	}
+
+	inside := tcell.NewEventMouse(15, 8, tcell.Button1, 0)
+	if consumed, _ := handler(tview.MouseLeftDown, inside, setFocus); !consumed {
+		t.Fatal("click inside the panel was not consumed")
+	}
+	if selected != panel {
+		t.Error("selected handler not called")
+	}
+	if focused != panel {
+		t.Errorf("focused %T, want the panel", focused)
+	}
+}
@@ -0,0 +1,94 @@
+//go:build !windows
+
+package app
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pashkov256/cmdpool/internal/config"
+	"github.com/pashkov256/cmdpool/internal/executor"
+	"github.com/rivo/tview"
+)
+
+// chattyCommand prints about 10k lines per second
+const chattyCommand = `sh -c "while true; do seq 1 1000; sleep 0.1; done"`
+
+// BenchmarkRenderPanels measures the CPU time of one 100ms UI tick with 20
+// commands each printing about 10k lines per second. "incremental" updates
+// command panels, "full" joins the whole output into a TextView each tick
+// like earlier versions did.
+func BenchmarkRenderPanels(b *testing.B) {
+	b.Run("incremental", func(b *testing.B) {
+		benchmarkRenderPanels(b, func(cmd *executor.Command) (tview.Primitive, func()) {
+			panel := NewCommandPanel(cmd)
+			return panel, func() { panel.updateDisplay() }
+		})
+	})
+	b.Run("full", func(b *testing.B) {
+		benchmarkRenderPanels(b, func(cmd *executor.Command) (tview.Primitive, func()) {
+			view := tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
+			view.SetBorder(true)
+			return view, func() {
+				var text strings.Builder
+				for _, line := range cmd.GetOutput() {
+					text.WriteString(line.Text + "\n")
+				}
+				view.SetText(text.String())
+			}
+		})
+	})
+}
+
+// benchmarkRenderPanels starts the chatty commands and calls the update
+// function of the view built for each before drawing all views
+func benchmarkRenderPanels(b *testing.B, build func(cmd *executor.Command) (tview.Primitive, func())) {
+	exec := executor.NewExecutor()
+	defer exec.Stop()
+
+	grid := tview.NewFlex()
+	updates := make([]func(), 20)
+	for i := range updates {
+		cmd := exec.Start(config.CommandEntry{Name: fmt.Sprintf("chatty %d", i), Run: chattyCommand, Dir: "."})
+		view, update := build(cmd)
+		grid.AddItem(view, 0, 1, false)
+		updates[i] = update
+	}
+
+	screen := tcell.NewSimulationScreen("UTF-8")
+	if err := screen.Init(); err != nil {
+		b.Fatal(err)
+	}
+	defer screen.Fini()
+	screen.SetSize(200, 60)
+
+	grid.SetRect(0, 0, 200, 60)
+
+	// Let the commands fill their buffers
+	time.Sleep(300 * time.Millisecond)
+
+	start := cpuTime()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		time.Sleep(100 * time.Millisecond)
+		for _, update := range updates {
+			update()
+		}
+		grid.Draw(screen)
+		screen.Show()
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(cpuTime()-start)/float64(time.Millisecond)/float64(b.N), "cpu-ms/tick")
+}
+
+// cpuTime returns the user and system CPU time used by the process
+func cpuTime() time.Duration {
+	var usage syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &usage)
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
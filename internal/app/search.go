@@ -0,0 +1,301 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// searchResultsPage is the name of the page listing global search hits
+const searchResultsPage = "search"
+
+// outputSearch is the search state of a panel
+type outputSearch struct {
+	pattern string
+	re      *regexp.Regexp
+	// filter hides lines without a match
+	filter bool
+	// Matches are numbered in order of output. current is the selected
+	// match, matches the number of matches seen and first the number of
+	// the oldest match still shown.
+	current int
+	matches int
+	first   int
+	// jump scrolls the selected match into view on the next update
+	jump bool
+}
+
+// compileSearch turns a search pattern into a regular expression. Patterns
+// that are not valid expressions are matched literally, and patterns
+// without upper case letters ignore case.
+func compileSearch(pattern string) *regexp.Regexp {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		re = regexp.MustCompile(regexp.QuoteMeta(pattern))
+	}
+	if strings.IndexFunc(pattern, unicode.IsUpper) < 0 {
+		re = regexp.MustCompile("(?i)" + re.String())
+	}
+	return re
+}
+
+// active reports whether the search has a pattern
+func (s *outputSearch) active() bool {
+	return s != nil && s.re != nil
+}
+
+// status describes the search for the panel title
+func (s *outputSearch) status() string {
+	if !s.active() {
+		return ""
+	}
+	text := fmt.Sprintf(" | /%s ", s.pattern)
+	if s.matches == s.first {
+		text += "no matches"
+	} else {
+		text += fmt.Sprintf("%d/%d", s.current-s.first+1, s.matches-s.first)
+	}
+	if s.filter {
+		text += " filtered"
+	}
+	return text
+}
+
+// searchable holds the search of a view whose output can be searched: a
+// panel or the timeline
+type searchable struct {
+	search *outputSearch
+}
+
+// setSearch starts a search in the view, or clears it for an empty pattern
+func (v *searchable) setSearch(pattern string) {
+	if pattern == "" {
+		v.clearSearch()
+		return
+	}
+
+	filter := v.search != nil && v.search.filter
+	v.search = &outputSearch{
+		pattern: pattern,
+		re:      compileSearch(pattern),
+		filter:  filter,
+		jump:    true,
+	}
+}
+
+// clearSearch removes the search and its highlights
+func (v *searchable) clearSearch() {
+	v.search = nil
+}
+
+// nextMatch selects the match delta steps away, wrapping around
+func (v *searchable) nextMatch(delta int) {
+	s := v.search
+	if !s.active() || s.matches == s.first {
+		return
+	}
+	count := s.matches - s.first
+	s.current = s.first + ((s.current-s.first+delta)%count+count)%count
+	s.jump = true
+}
+
+// toggleFilter switches between showing all lines and only matching ones
+func (v *searchable) toggleFilter() {
+	if !v.search.active() {
+		return
+	}
+	v.search.filter = !v.search.filter
+	v.search.jump = true
+}
+
+// searchTarget returns the search of the view shown: the timeline or the
+// selected panel
+func (ui *TUI) searchTarget() *searchable {
+	if ui.timeline != nil {
+		return &ui.timeline.searchable
+	}
+	if panel := ui.selectedCommandPanel(); panel != nil {
+		return &panel.searchable
+	}
+	return nil
+}
+
+// withSearch calls fn with the search of the view shown, if any, and
+// refreshes the interface
+func (ui *TUI) withSearch(fn func(v *searchable)) {
+	if target := ui.searchTarget(); target != nil {
+		fn(target)
+		ui.updateUI()
+	}
+}
+
+// startSearch shows the search input. A global search lists the matching
+// lines of every command once the pattern is entered, a search of the
+// selected panel or the timeline updates it while typing.
+func (ui *TUI) startSearch(global bool) {
+	target := ui.searchTarget()
+	if target == nil {
+		return
+	}
+
+	label := "/"
+	if global {
+		label = "Search all: "
+	}
+	input := tview.NewInputField().
+		SetLabel(label).
+		SetFieldBackgroundColor(tcell.ColorDefault)
+	if !global && target.search.active() {
+		input.SetText(target.search.pattern)
+	}
+
+	if !global {
+		input.SetChangedFunc(func(text string) {
+			target.setSearch(text)
+			ui.updateUI()
+		})
+	}
+
+	// The input takes the place of the help bar of the current view
+	layout, helpBar := ui.mainLayout, ui.helpBar
+	switch {
+	case ui.timeline != nil:
+		layout, helpBar = ui.timeline.layout, ui.timeline.helpBar
+	case ui.zoom != nil:
+		layout, helpBar = ui.zoom.layout, ui.zoom.helpBar
+	}
+
+	// Submitting keeps the pattern, or lists the results of a global
+	// search; cancelling drops it
+	ui.searchInput = input
+	ui.finishSearch = func(cancel bool) {
+		ui.searchInput, ui.finishSearch = nil, nil
+		layout.RemoveItem(input)
+		layout.AddItem(helpBar, 1, 0, false)
+		ui.focusFront()
+
+		switch {
+		case cancel && !global:
+			target.clearSearch()
+			ui.updateUI()
+		case !cancel && global:
+			ui.showSearchResults(input.GetText())
+		}
+	}
+	// Keys that end the input but are not bound submit, such as Tab
+	input.SetDoneFunc(func(key tcell.Key) {
+		if ui.finishSearch != nil {
+			ui.finishSearch(key == tcell.KeyEscape)
+		}
+	})
+
+	layout.RemoveItem(helpBar)
+	layout.AddItem(input, 1, 0, true)
+	ui.app.SetFocus(input)
+}
+
+// showSearchResults lists the lines of every command matching pattern,
+// grouped by command. Selecting a line jumps to it in its panel.
+func (ui *TUI) showSearchResults(pattern string) {
+	if pattern == "" {
+		return
+	}
+	re := compileSearch(pattern)
+
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true).SetTitle(fmt.Sprintf(" Search: %s ", pattern))
+
+	closeResults := func() {
+		ui.pages.RemovePage(searchResultsPage)
+		ui.focusFront()
+	}
+
+	hits := 0
+	for i, panel := range ui.commandPanels {
+		index := i
+		var lines []string
+		var firstMatch []int
+		matches := 0
+		for _, line := range panel.command.GetOutput() {
+			count := countMatches(re, line.Text)
+			if count == 0 {
+				continue
+			}
+			lines = append(lines, line.Text)
+			firstMatch = append(firstMatch, matches)
+			matches += count
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		hits += matches
+
+		list.AddItem(fmt.Sprintf("[yellow::b]%s[-::-] (%d)", tview.Escape(panel.command.Name), matches), "", 0, nil)
+		for j, line := range lines {
+			match := firstMatch[j]
+			list.AddItem("  "+highlightMatches(re, line, 0, -1), "", 0, func() {
+				closeResults()
+				ui.selectPanel(index)
+				target := ui.commandPanels[index]
+				target.setSearch(pattern)
+				target.search.current = match
+				if ui.zoom != nil && ui.zoom.panel != target {
+					ui.closeZoom()
+					ui.openZoom(target)
+				}
+				ui.updateUI()
+			})
+		}
+	}
+
+	if hits == 0 {
+		ui.showMessage(fmt.Sprintf("No matches for %s", pattern), tcell.ColorYellow)
+		return
+	}
+
+	list.SetDoneFunc(closeResults)
+	ui.pages.AddPage(searchResultsPage, list, true, true)
+	ui.app.SetFocus(list)
+}
+
+// countMatches returns the number of non-empty matches in a line
+func countMatches(re *regexp.Regexp, line string) int {
+	count := 0
+	for _, loc := range re.FindAllStringIndex(line, -1) {
+		if loc[0] != loc[1] {
+			count++
+		}
+	}
+	return count
+}
+
+// highlightMatches escapes a line and colours its matches. Matches are
+// numbered from first; the one numbered current stands out. Empty matches
+// of patterns such as "a*" cannot be shown and are skipped.
+// Colour tags are used rather than region tags since output views print
+// only the visible lines with tview.Print, which does not know regions;
+// scrollToMatch finds the current match by its number instead.
+func highlightMatches(re *regexp.Regexp, line string, first, current int) string {
+	var b strings.Builder
+	last := 0
+	n := first
+	for _, loc := range re.FindAllStringIndex(line, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		color := "[black:yellow]"
+		if n == current {
+			color = "[black:orange:b]"
+		}
+		b.WriteString(tview.Escape(line[last:loc[0]]))
+		b.WriteString(color + tview.Escape(line[loc[0]:loc[1]]) + "[-:-:-]")
+		last = loc[1]
+		n++
+	}
+	b.WriteString(tview.Escape(line[last:]))
+	return b.String()
+}
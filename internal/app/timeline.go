@@ -0,0 +1,295 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pashkov256/cmdpool/internal/config"
+	"github.com/pashkov256/cmdpool/internal/executor"
+	"github.com/rivo/tview"
+)
+
+// Names of the pages of the timeline and of its command selection
+const (
+	timelinePage       = "timeline"
+	timelineSelectPage = "timeline-select"
+)
+
+// timelineColors are given to the names of commands without a colour of
+// their own, in order
+var timelineColors = []string{"aqua", "fuchsia", "yellow", "lime", "orange", "violet", "skyblue", "salmon"}
+
+// timeline merges the output of several commands into one stream ordered
+// by the time lines were read. It numbers the merged lines itself, so it
+// can be shown by an output view like a single command.
+type timeline struct {
+	exec *executor.Executor
+	// only holds the names of the commands shown, or is nil for all
+	only map[string]bool
+	// cursors is the sequence number of the last line taken per command
+	cursors map[*executor.Command]uint64
+	lines   []outputLine
+	// seq is the sequence number of the newest merged line
+	seq uint64
+}
+
+// newTimeline creates a timeline of the commands named in only, or of all
+// commands if only is nil, starting with the output they still hold
+func newTimeline(exec *executor.Executor, only map[string]bool) *timeline {
+	return &timeline{
+		exec:    exec,
+		only:    only,
+		cursors: make(map[*executor.Command]uint64),
+	}
+}
+
+// commands returns the commands shown, in the order of the executor
+func (t *timeline) commands() []*executor.Command {
+	var cmds []*executor.Command
+	for _, cmd := range t.exec.List() {
+		if t.only == nil || t.only[cmd.Name] {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
+// poll takes the lines the commands added since the last poll and merges
+// them by time. Lines of one poll are sorted among themselves; later polls
+// only find lines read after earlier ones.
+func (t *timeline) poll() {
+	cmds := t.commands()
+	labels := timelineLabels(cmds)
+
+	var fresh []outputLine
+	for _, cmd := range cmds {
+		lines, next, _ := cmd.OutputSince(t.cursors[cmd])
+		t.cursors[cmd] = next
+		for _, line := range lines {
+			fresh = append(fresh, outputLine{text: line.Text, stream: line.Stream, level: line.Level, time: line.Time, label: labels[cmd], name: cmd.Name})
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+
+	sort.SliceStable(fresh, func(i, j int) bool {
+		return fresh[i].time.Before(fresh[j].time)
+	})
+	for i := range fresh {
+		t.seq++
+		fresh[i].seq = t.seq
+	}
+	t.lines = append(t.lines, fresh...)
+	if extra := len(t.lines) - t.limit(); extra > 0 {
+		n := copy(t.lines, t.lines[extra:])
+		t.lines = t.lines[:n]
+	}
+}
+
+// limit returns how many merged lines the timeline keeps
+func (t *timeline) limit() int {
+	return t.exec.MaxOutputLines()
+}
+
+// linesSince returns the merged lines after seq, like
+// executor.Command.OutputSince
+func (t *timeline) linesSince(seq uint64) ([]outputLine, uint64, bool) {
+	t.poll()
+
+	first := t.seq - uint64(len(t.lines)) + 1
+	start, reset := 0, false
+	switch {
+	case seq+1 < first:
+		reset = true
+	case seq < t.seq:
+		start = int(seq + 1 - first)
+	default:
+		start = len(t.lines)
+	}
+
+	lines := make([]outputLine, len(t.lines)-start)
+	copy(lines, t.lines[start:])
+	return lines, t.seq, reset
+}
+
+// timelineLabels returns the coloured name shown in front of the lines of
+// each command, padded to the longest name
+func timelineLabels(cmds []*executor.Command) map[*executor.Command]string {
+	width := 0
+	for _, cmd := range cmds {
+		width = max(width, tview.TaggedStringWidth(tview.Escape(cmd.Name)))
+	}
+
+	labels := make(map[*executor.Command]string, len(cmds))
+	for i, cmd := range cmds {
+		color := cmd.Color
+		if color == "" {
+			color = timelineColors[i%len(timelineColors)]
+		}
+		name := tview.Escape(cmd.Name)
+		padding := strings.Repeat(" ", width-tview.TaggedStringWidth(name))
+		labels[cmd] = fmt.Sprintf("[%s]%s[-]%s │ ", color, name, padding)
+	}
+	return labels
+}
+
+// timelineView shows a timeline full screen. It can be searched like a
+// panel, and frozen to look at a moment while the commands go on.
+type timelineView struct {
+	searchable
+	source *timeline
+	layout *tview.Flex
+	header *tview.TextView
+	// shownHeader is the text of the header as last rendered
+	shownHeader string
+	output      *outputView
+	helpBar     *tview.TextView
+	// show selects the lines shown
+	show lineFilter
+	// frozen stops taking new lines
+	frozen bool
+}
+
+// newTimelineView builds the view of a timeline with the given help bar
+// text
+func newTimelineView(source *timeline, help string) *timelineView {
+	v := &timelineView{
+		source: source,
+	}
+
+	v.header = tview.NewTextView().
+		SetDynamicColors(true)
+
+	v.output = newOutputView()
+
+	v.helpBar = tview.NewTextView().
+		SetTextAlign(tview.AlignCenter).
+		SetText(help).
+		SetTextColor(tcell.ColorGray)
+
+	v.layout = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(v.header, 1, 0, false).
+		AddItem(v.output, 0, 1, true).
+		AddItem(v.helpBar, 1, 0, false)
+	v.layout.SetBorder(true)
+
+	v.update()
+	return v
+}
+
+// update takes new lines unless the view is frozen and reports whether
+// anything changed
+func (v *timelineView) update() bool {
+	changed := false
+	if v.frozen {
+		// Keep merging so nothing is missed once the view thaws
+		v.source.poll()
+	} else {
+		changed, _ = v.output.update(v.source, v.search, v.show)
+	}
+
+	var names []string
+	for _, cmd := range v.source.commands() {
+		names = append(names, cmd.Name)
+	}
+	mode := "[green]following[-]"
+	switch {
+	case v.frozen:
+		mode = "[aqua]frozen[-]"
+	case !v.output.following():
+		mode = "[yellow]paused[-]"
+	}
+	if header := tview.Escape(strings.Join(names, ", ")) + " | " + mode; header != v.shownHeader {
+		v.shownHeader = header
+		v.header.SetText(header)
+		changed = true
+	}
+
+	title := " Timeline" + v.show.title() + v.search.status() + " "
+	if title != v.layout.GetTitle() {
+		v.layout.SetTitle(title)
+		changed = true
+	}
+	return changed
+}
+
+// toggleFreeze stops or resumes taking new lines. Freezing keeps the lines
+// shown in view.
+func (v *timelineView) toggleFreeze() {
+	v.frozen = !v.frozen
+	v.output.setFollow(!v.frozen)
+	v.update()
+}
+
+// toggleTimeline shows the timeline of all commands, or returns to the
+// panels
+func (ui *TUI) toggleTimeline() {
+	if ui.timeline != nil {
+		ui.closeTimeline()
+		return
+	}
+	ui.openTimeline(nil)
+}
+
+// openTimeline shows the timeline of the commands named in only, or of
+// all commands if only is nil
+func (ui *TUI) openTimeline(only map[string]bool) {
+	ui.closeZoom()
+	ui.closeTimeline()
+
+	ui.timeline = newTimelineView(newTimeline(ui.executor, only), ui.keys.helpBarText(config.KeyContextTimeline))
+	ui.timeline.output.setTimestamps(ui.timestamps, ui.started)
+	ui.pages.AddPage(timelinePage, ui.timeline.layout, true, true)
+	ui.pages.HidePage("main")
+	ui.app.SetFocus(ui.timeline.output)
+}
+
+// closeTimeline returns from the timeline to the panels
+func (ui *TUI) closeTimeline() {
+	if ui.timeline == nil {
+		return
+	}
+	ui.timeline = nil
+	ui.pages.RemovePage(timelinePage)
+	ui.pages.ShowPage("main")
+	ui.focusFront()
+}
+
+// selectTimelineCommands lets the user pick the commands of the timeline.
+// The timeline is built again from the output the chosen commands hold.
+func (ui *TUI) selectTimelineCommands() {
+	if ui.timeline == nil {
+		return
+	}
+	shown := make(map[string]bool)
+	for _, cmd := range ui.timeline.source.commands() {
+		shown[cmd.Name] = true
+	}
+
+	cmds := ui.executor.List()
+	form := tview.NewForm().SetItemPadding(0)
+	for _, cmd := range cmds {
+		form.AddCheckbox(cmd.Name, shown[cmd.Name], nil)
+	}
+	form.AddButton("Show", func() {
+		only := make(map[string]bool)
+		for i, cmd := range cmds {
+			if form.GetFormItem(i).(*tview.Checkbox).IsChecked() {
+				only[cmd.Name] = true
+			}
+		}
+		if len(only) == len(cmds) {
+			only = nil
+		}
+		ui.closeDialog()
+		ui.openTimeline(only)
+	})
+	form.AddButton("Cancel", ui.closeDialog)
+	form.SetCancelFunc(ui.closeDialog)
+	form.SetBorder(true).SetTitle(" Timeline commands ")
+
+	ui.showDialog(timelineSelectPage, form, 48, min(len(cmds)+6, 24))
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/pashkov256/cmdpool/internal/executor"
+)
+
+// timestampMode is how the time of output lines is shown in the gutter
+type timestampMode int
+
+const (
+	timestampsOff timestampMode = iota
+	// timestampsAbsolute shows the time of day
+	timestampsAbsolute
+	// timestampsRelative shows the time since cmdpool started, which is the
+	// same reference for every panel
+	timestampsRelative
+	// timestampsDelta shows the time since the previous line shown
+	timestampsDelta
+)
+
+// String returns the name of the mode shown in the status bar
+func (m timestampMode) String() string {
+	switch m {
+	case timestampsAbsolute:
+		return "absolute"
+	case timestampsRelative:
+		return "relative to start"
+	case timestampsDelta:
+		return "since previous line"
+	}
+	return "off"
+}
+
+// cycleTimestamps switches every panel to the next timestamp mode
+func (ui *TUI) cycleTimestamps() {
+	ui.timestamps = (ui.timestamps + 1) % (timestampsDelta + 1)
+	for _, panel := range ui.commandPanels {
+		panel.output.setTimestamps(ui.timestamps, ui.started)
+	}
+	if ui.zoom != nil {
+		ui.zoom.output.setTimestamps(ui.timestamps, ui.started)
+	}
+	if ui.timeline != nil {
+		ui.timeline.output.setTimestamps(ui.timestamps, ui.started)
+	}
+	ui.showMessage(fmt.Sprintf("Timestamps: %s", ui.timestamps), tcell.ColorGreen)
+}
+
+// setTimestamps sets how the view shows the time of its lines; start is the
+// reference of relative times
+func (o *outputView) setTimestamps(mode timestampMode, start time.Time) {
+	o.timestamps = mode
+	o.started = start
+}
+
+// gutter returns the time of a line as shown in front of it, or an empty
+// string when timestamps are off
+func (o *outputView) gutter(index int) string {
+	line := o.lines[index]
+	var text string
+	switch o.timestamps {
+	case timestampsOff:
+		return ""
+	case timestampsAbsolute:
+		text = line.time.Format(executor.TimeFormat)
+	case timestampsRelative:
+		text = formatOffset(line.time.Sub(o.started))
+	case timestampsDelta:
+		previous := line.time
+		if index > 0 {
+			previous = o.lines[index-1].time
+		}
+		text = formatOffset(line.time.Sub(previous))
+	}
+	return fmt.Sprintf("[gray]%s[-] ", text)
+}
+
+// formatOffset writes a duration as +M:SS.mmm, with hours in front once it
+// reaches an hour
+func formatOffset(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	ms := d.Milliseconds()
+	hours, minutes := ms/3600000, ms/60000%60
+	seconds, millis := ms/1000%60, ms%1000
+	if hours > 0 {
+		return fmt.Sprintf("%s%d:%02d:%02d.%03d", sign, hours, minutes, seconds, millis)
+	}
+	return fmt.Sprintf("%s%d:%02d.%03d", sign, minutes, seconds, millis)
+}
@@ -1,8 +1,11 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"io/fs"
+	"sync/atomic"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
@@ -16,22 +19,47 @@ type TUI struct {
 	app           *tview.Application
 	executor      *executor.Executor
 	config        *config.Config
+	pages         *tview.Pages
 	mainLayout    *tview.Flex
+	panelsArea    *tview.Flex
+	layoutMode    config.LayoutMode
+	layoutTree    *config.LayoutNode
 	commandPanels []*CommandPanel
 	statusBar     *tview.TextView
 	helpBar       *tview.TextView
 	selectedPanel int
+	zoom          *zoomView
+	timeline      *timelineView
+	keys          *keymap
+	// timestamps is how the panels show the time of output lines; relative
+	// times count from started
+	timestamps timestampMode
+	started    time.Time
+	// searchInput is the search pattern being typed, if any, and
+	// finishSearch ends that search
+	searchInput  *tview.InputField
+	finishSearch func(cancel bool)
+	message      string
+	messageColor tcell.Color
+	messageUntil time.Time
+	// shownStatus is the status bar text as last rendered
+	shownStatus      string
+	shownStatusColor tcell.Color
+	// bell is set when an alert rings the bell on the next draw, rang is
+	// when it last rang
+	bell atomic.Bool
+	rang time.Time
+	// clipboard is text to copy to the clipboard after the next draw
+	clipboard atomic.Pointer[string]
+	// quitting is set once cmdpool started stopping its commands to exit
+	quitting bool
 }
 
-// CommandPanel represents a single command display panel
-type CommandPanel struct {
-	*tview.Box
-	command  *executor.Command
-	output   *tview.TextView
-	status   *tview.TextView
-	title    *tview.TextView
-	expanded bool
-}
+// configPollInterval is how often the config files are checked for changes
+const configPollInterval = 500 * time.Millisecond
+
+// messageDuration is how long a message stays in the status bar
+const messageDuration = 5 * time.Second
 
 // NewTUI creates a new TUI instance
 func NewTUI() *TUI {
@@ -40,9 +68,13 @@ func NewTUI() *TUI {
 		executor:      executor.NewExecutor(),
 		commandPanels: make([]*CommandPanel, 0),
 		selectedPanel: 0,
+		layoutMode:    config.LayoutGrid,
+		keys:          newKeymap(nil),
+		started:       time.Now(),
 	}
 
 	tui.setupUI()
+	tui.app.SetAfterDrawFunc(tui.afterDraw)
 	tui.setupKeyBindings()
 	tui.setupUpdateLoop()
 
@@ -55,8 +87,8 @@ func (tui *TUI) setupUI() {
 	tui.mainLayout = tview.NewFlex().SetDirection(tview.FlexRow)
 
 	// Create command panels area
-	panelsArea := tview.NewFlex().SetDirection(tview.FlexColumn)
-	tui.mainLayout.AddItem(panelsArea, 0, 1, true)
+	tui.panelsArea = tview.NewFlex().SetDirection(tview.FlexRow)
+	tui.mainLayout.AddItem(tui.panelsArea, 0, 1, true)
 
 	// Create status bar
 	tui.statusBar = tview.NewTextView().
@@ -67,72 +99,67 @@ func (tui *TUI) setupUI() {
 	// Create help bar
 	tui.helpBar = tview.NewTextView().
 		SetTextAlign(tview.AlignCenter).
-		SetText("↑↓: Navigate | Enter: Expand | r: Restart | s: Stop | +: Add | q: Quit").
+		SetText(tui.keys.helpBarText(config.KeyContextList)).
 		SetTextColor(tcell.ColorGray)
 
 	// Add status and help bars
 	tui.mainLayout.AddItem(tui.statusBar, 1, 0, false)
 	tui.mainLayout.AddItem(tui.helpBar, 1, 0, false)
 
+	// Dialogs and overlays are pages on top of the main layout
+	tui.pages = tview.NewPages().AddPage("main", tui.mainLayout, true, true)
+
 	// Set root
-	tui.app.SetRoot(tui.mainLayout, true)
+	tui.app.SetRoot(tui.pages, true).EnableMouse(true)
 }
 
-// setupKeyBindings sets up keyboard shortcuts
+// setupKeyBindings runs the action bound to a key in the context of the
+// focused view. Keys without an action are left to the view.
 func (ui *TUI) setupKeyBindings() {
 	ui.app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Key() {
-		case tcell.KeyUp:
-			ui.selectPreviousPanel()
-			return nil
-		case tcell.KeyDown:
-			ui.selectNextPanel()
-			return nil
-		case tcell.KeyLeft:
-			ui.selectPreviousPanel()
-			return nil
-		case tcell.KeyRight:
-			ui.selectNextPanel()
-			return nil
-		case tcell.KeyEnter:
-			ui.expandSelectedPanel()
+		context, ok := ui.keyContext()
+		if !ok {
+			return event
+		}
+		if action := ui.keys.action(context, event); action != "" && ui.runAction(context, action) {
 			return nil
-		case tcell.KeyRune:
-			switch event.Rune() {
-			case 'r':
-				ui.restartSelectedCommand()
-				return nil
-			case 's':
-				ui.stopSelectedCommand()
-				return nil
-			case '+':
-				ui.addNewCommand()
-				return nil
-			case 'q':
-				ui.quit()
-				return nil
-			}
 		}
 		return event
 	})
 }
 
+// afterDraw talks to the terminal once a draw is done: it rings the bell
+// and sends copied text
+func (ui *TUI) afterDraw(screen tcell.Screen) {
+	ui.ringBell(screen)
+	ui.flushClipboard()
+}
+
 // setupUpdateLoop starts the UI update loop
 func (ui *TUI) setupUpdateLoop() {
 	go func() {
 		ticker := time.NewTicker(100 * time.Millisecond)
 		defer ticker.Stop()
 
+		// Only redraw when something visible changed
+		changed := make(chan bool, 1)
 		for range ticker.C {
-			ui.app.QueueUpdateDraw(func() {
-				ui.updateUI()
+			ui.app.QueueUpdate(func() {
+				changed <- ui.updateUI()
 			})
+			if <-changed {
+				ui.app.Draw()
+			}
 		}
 	}()
 }
 
-// updateUI updates the interface elements
-func (ui *TUI) updateUI() {
+// updateUI updates the interface elements and reports whether anything
+// changed
+func (ui *TUI) updateUI() bool {
+	// New alerts show a message, so check them before the status bar
+	alerted := ui.checkAlerts()
+
 	// Update status bar
 	commands := ui.executor.GetCommands()
 	running := 0
@@ -140,7 +167,7 @@ func (ui *TUI) updateUI() {
 	failed := 0
 
 	for _, cmd := range commands {
-		switch cmd.Status {
+		switch cmd.GetStatus() {
 		case executor.StatusRunning:
 			running++
 		case executor.StatusDone:
@@ -151,12 +178,60 @@ func (ui *TUI) updateUI() {
 	}
 
 	statusText := fmt.Sprintf("cmdpool - Running: %d | Done: %d | Failed: %d", running, done, failed)
-	ui.statusBar.SetText(statusText)
+	statusColor := tcell.ColorYellow
+
+	// Count the errors and warnings logged by commands with a log format
+	for _, cmd := range ui.executor.List() {
+		if errorCount, warningCount := cmd.LevelCounts(); errorCount+warningCount > 0 {
+			statusText += fmt.Sprintf(" | %s: %d err, %d warn", cmd.Name, errorCount, warningCount)
+		}
+	}
 
-	// Update command panels
+	// Show progress of sequential sets that are still going
+	for _, group := range ui.executor.GetGroups() {
+		if group.Mode != config.ModeSequential {
+			continue
+		}
+		if step, total := group.Progress(); total > 0 && !group.Finished() {
+			statusText += fmt.Sprintf(" | %s %d/%d", group.Name, step, total)
+		}
+	}
+	if time.Now().Before(ui.messageUntil) {
+		statusText = ui.message
+		statusColor = ui.messageColor
+	}
+
+	changed := alerted
+	if statusText != ui.shownStatus || statusColor != ui.shownStatusColor {
+		ui.shownStatus, ui.shownStatusColor = statusText, statusColor
+		ui.statusBar.SetText(statusText)
+		ui.statusBar.SetTextColor(statusColor)
+		changed = true
+	}
+
+	// Only the visible view needs its output rendered
+	if ui.timeline != nil {
+		return ui.timeline.update() || changed
+	}
+	if ui.zoom != nil {
+		return ui.zoom.update() || changed
+	}
 	for _, panel := range ui.commandPanels {
-		panel.updateDisplay()
+		if panel.updateDisplay() {
+			changed = true
+		}
 	}
+	return changed
+}
+
+// showMessage shows a message in the status bar for a few seconds
+func (ui *TUI) showMessage(text string, color tcell.Color) {
+	ui.message = text
+	ui.messageColor = color
+	ui.messageUntil = time.Now().Add(messageDuration)
+	ui.shownStatus, ui.shownStatusColor = text, color
+	ui.statusBar.SetText(text)
+	ui.statusBar.SetTextColor(color)
 }
 
 // selectNextPanel selects the next panel
@@ -179,6 +254,39 @@ func (ui *TUI) selectPreviousPanel() {
 	ui.updatePanelSelection()
 }
 
+// selectPanel selects the panel at index
+func (ui *TUI) selectPanel(index int) {
+	if index < 0 || index >= len(ui.commandPanels) {
+		return
+	}
+	ui.selectedPanel = index
+	ui.updatePanelSelection()
+}
+
+// selectedCommandPanel returns the selected panel, or nil without panels
+func (ui *TUI) selectedCommandPanel() *CommandPanel {
+	if ui.selectedPanel >= len(ui.commandPanels) {
+		return nil
+	}
+	return ui.commandPanels[ui.selectedPanel]
+}
+
+// withSelectedPanel calls fn with the selected panel, if any, and
+// refreshes the interface
+func (ui *TUI) withSelectedPanel(fn func(panel *CommandPanel)) {
+	if panel := ui.selectedCommandPanel(); panel != nil {
+		fn(panel)
+		ui.updateUI()
+	}
+}
+
+// focusFront gives the focus to the page shown on top
+func (ui *TUI) focusFront() {
+	if _, page := ui.pages.GetFrontPage(); page != nil {
+		ui.app.SetFocus(page)
+	}
+}
+
 // updatePanelSelection updates the visual selection
 func (ui *TUI) updatePanelSelection() {
 	for i, panel := range ui.commandPanels {
@@ -190,196 +298,229 @@ func (ui *TUI) updatePanelSelection() {
 			panel.SetBorder(true)
 		}
 	}
-}
 
-// expandSelectedPanel expands the selected panel to full screen
-func (ui *TUI) expandSelectedPanel() {
-	if len(ui.commandPanels) == 0 || ui.selectedPanel >= len(ui.commandPanels) {
-		return
+	// Tabs show only the selected panel
+	if ui.layoutMode == config.LayoutTabs {
+		ui.arrangePanels()
 	}
-
-	panel := ui.commandPanels[ui.selectedPanel]
-	panel.expand()
 }
 
 // restartSelectedCommand restarts the selected command
 func (ui *TUI) restartSelectedCommand() {
-	if len(ui.commandPanels) == 0 || ui.selectedPanel >= len(ui.commandPanels) {
-		return
-	}
-
-	panel := ui.commandPanels[ui.selectedPanel]
-	if err := ui.executor.RestartCommand(panel.command.ID); err != nil {
-		// Show error in status bar
-		ui.statusBar.SetText(fmt.Sprintf("Error restarting command: %v", err))
-		ui.statusBar.SetTextColor(tcell.ColorRed)
+	if panel := ui.selectedCommandPanel(); panel != nil {
+		ui.restartCommand(panel.command)
 	}
 }
 
 // stopSelectedCommand stops the selected command
 func (ui *TUI) stopSelectedCommand() {
-	if len(ui.commandPanels) == 0 || ui.selectedPanel >= len(ui.commandPanels) {
-		return
+	if panel := ui.selectedCommandPanel(); panel != nil {
+		ui.stopCommand(panel.command)
 	}
+}
 
-	panel := ui.commandPanels[ui.selectedPanel]
-	if err := ui.executor.StopCommand(panel.command.ID); err != nil {
-		// Show error in status bar
-		ui.statusBar.SetText(fmt.Sprintf("Error stopping command: %v", err))
-		ui.statusBar.SetTextColor(tcell.ColorRed)
+// quitPage is the name of the page asking to confirm quitting
+const quitPage = "quit"
+
+// showDialog shows a primitive of the given size centered on top of the
+// current view and focuses it
+func (ui *TUI) showDialog(name string, dialog tview.Primitive, width, height int) {
+	box := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(dialog, height, 0, true).
+			AddItem(nil, 0, 1, false), width, 0, true).
+		AddItem(nil, 0, 1, false)
+
+	ui.pages.AddPage(name, box, true, true)
+	ui.app.SetFocus(dialog)
+}
+
+// closeDialog removes the dialog or overlay shown on top, if any
+func (ui *TUI) closeDialog() {
+	name, _ := ui.pages.GetFrontPage()
+	if name == "main" || name == zoomPage || name == timelinePage {
+		return
 	}
+	ui.pages.RemovePage(name)
+	ui.focusFront()
 }
 
-// addNewCommand adds a new command via input dialog
-func (ui *TUI) addNewCommand() {
-	// Create input dialog
-	inputField := tview.NewInputField().
-		SetLabel("Command: ").
-		SetFieldWidth(50)
+// quit exits the application. While commands are running it asks first,
+// since quitting stops them.
+func (ui *TUI) quit() {
+	if ui.quitting {
+		return
+	}
+
+	running := 0
+	for _, cmd := range ui.executor.GetCommands() {
+		if cmd.GetStatus() == executor.StatusRunning {
+			running++
+		}
+	}
+	if running == 0 {
+		ui.stopAndQuit()
+		return
+	}
 
 	modal := tview.NewModal().
-		SetText("Enter command to execute:").
-		AddButtons([]string{"Run", "Cancel"}).
+		SetText(fmt.Sprintf("%d command(s) still running.\nStop them and quit?", running)).
+		AddButtons([]string{"Quit", "Cancel"}).
 		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-			if buttonIndex == 0 { // Run button
-				command := inputField.GetText()
-				if command != "" {
-					ui.executor.RunCommands([]string{command})
-					ui.app.SetRoot(ui.mainLayout, true)
-				}
-			} else {
-				ui.app.SetRoot(ui.mainLayout, true)
+			ui.closeDialog()
+			if buttonLabel == "Quit" {
+				ui.stopAndQuit()
 			}
 		})
-
-	// Show modal
-	ui.app.SetRoot(modal, true)
+	ui.pages.AddPage(quitPage, modal, true, true)
+	ui.app.SetFocus(modal)
 }
 
-// quit exits the application
-func (ui *TUI) quit() {
-	ui.executor.Stop()
-	ui.app.Stop()
+// stopAndQuit stops every command in the background, keeping the UI
+// responsive while stop hooks run, and exits once they have stopped
+func (ui *TUI) stopAndQuit() {
+	ui.quitting = true
+	ui.showMessage("Stopping commands", tcell.ColorYellow)
+	go func() {
+		ui.executor.Stop()
+		ui.app.Stop()
+	}()
 }
 
 // AddCommand adds a new command panel
 func (ui *TUI) AddCommand(command *executor.Command) {
-	panel := NewCommandPanel(command)
+	panel := ui.newPanel(command)
 	ui.commandPanels = append(ui.commandPanels, panel)
 
 	// Add to panels area
-	panelsArea := ui.mainLayout.GetItem(0).(*tview.Flex)
-	panelsArea.AddItem(panel, 0, 1, false)
+	ui.arrangePanels()
 
 	// Update selection
 	ui.updatePanelSelection()
 }
 
-// Run starts the TUI
-func (ui *TUI) Run() error {
-	return ui.app.Run()
-}
-
-// NewCommandPanel creates a new command panel
-func NewCommandPanel(command *executor.Command) *CommandPanel {
-	panel := &CommandPanel{
-		Box:      tview.NewBox().SetBorder(true),
-		command:  command,
-		expanded: false,
+// LoadConfig applies the settings of the config. Its command sets are not
+// started; the command palette offers to start them.
+func (ui *TUI) LoadConfig(cfg *config.Config) {
+	ui.config = cfg
+	ui.applyLayoutConfig(cfg)
+	ui.applyKeys(cfg.Keys)
+	ui.executor.SetHooks(cfg.Global.Hooks)
+	ui.executor.SetMaxLineLength(cfg.Global.MaxLineLength)
+	ui.executor.SetOutputLimits(cfg.Global.MaxOutput, cfg.Global.MaxOutputBytes, cfg.Global.SpillOutput)
+	ui.executor.SetAlerts(cfg.Alerts)
+	ui.executor.SetMasking(cfg.Global.Mask)
+	if keys := ui.keys.keys(config.KeyContextList, config.ActionPalette); len(keys) > 0 && len(cfg.CommandSets) > 0 {
+		ui.showMessage(fmt.Sprintf("Press %s to start a command set", displayKey(keys[0])), tcell.ColorYellow)
 	}
 
-	// Create title
-	panel.title = tview.NewTextView().
-		SetText(command.Name).
-		SetTextAlign(tview.AlignCenter).
-		SetTextColor(tcell.ColorWhite)
+	go ui.watchConfig(cfg)
+}
 
-	// Create status
-	panel.status = tview.NewTextView().
-		SetText(string(command.Status)).
-		SetTextAlign(tview.AlignRight).
-		SetTextColor(tcell.ColorYellow)
+// watchConfig reloads the config whenever one of its files changes and
+// restarts only the commands whose definition changed. Sets that were
+// never started are left alone.
+func (ui *TUI) watchConfig(cfg *config.Config) {
+	current := cfg
+	current.Watch(context.Background(), configPollInterval, func(next *config.Config, err error) {
+		if err != nil {
+			ui.app.QueueUpdateDraw(func() {
+				ui.showMessage(fmt.Sprintf("Config not reloaded: %v", err), tcell.ColorRed)
+			})
+			return
+		}
 
-	// Create output
-	panel.output = tview.NewTextView().
-		SetDynamicColors(true).
-		SetScrollable(true).
-		SetTextColor(tcell.ColorGreen)
+		diff := ui.executor.Reload(ui.startedSets(current), ui.startedSets(next))
+		current = next
+		ui.app.QueueUpdateDraw(func() {
+			// Saving the layout mode reloads the config without changes
+			ownSave := diff.Empty() && next.Layout != nil && next.Layout.Mode == ui.layoutMode
+
+			ui.config = next
+			ui.applyLayoutConfig(next)
+			ui.applyKeys(next.Keys)
+			ui.syncPanels()
+			if !ownSave {
+				ui.showMessage("Config reloaded: "+diff.Summary(), tcell.ColorGreen)
+			}
+		})
+	})
+}
 
-	// Set up layout
-	panel.SetBorder(true)
-	panel.SetTitle(fmt.Sprintf(" %s ", command.Name))
+// startedSets returns a copy of cfg holding only the command sets that
+// have been started
+func (ui *TUI) startedSets(cfg *config.Config) *config.Config {
+	started := make(map[string]bool)
+	for _, group := range ui.executor.GetGroups() {
+		started[group.Key] = true
+	}
 
-	return panel
+	result := *cfg
+	result.CommandSets = make(map[string]config.CommandSet)
+	for key, set := range cfg.CommandSets {
+		if started[key] {
+			result.CommandSets[key] = set
+		}
+	}
+	return &result
 }
 
-// updateDisplay updates the panel display
-func (panel *CommandPanel) updateDisplay() {
-	// Update status
-	statusText := string(panel.command.Status)
-	statusColor := tcell.ColorWhite
-
-	switch panel.command.Status {
-	case executor.StatusRunning:
-		statusText = "🟢 Running"
-		statusColor = tcell.ColorGreen
-	case executor.StatusDone:
-		statusText = "✅ Done"
-		statusColor = tcell.ColorGreen
-	case executor.StatusFailed:
-		statusText = "🔴 Failed"
-		statusColor = tcell.ColorRed
-	case executor.StatusStopped:
-		statusText = "⏹️ Stopped"
-		statusColor = tcell.ColorYellow
+// syncPanels makes the panels match the commands known to the executor,
+// keeping existing panels for commands that are still there
+func (ui *TUI) syncPanels() {
+	existing := make(map[*executor.Command]*CommandPanel)
+	for _, panel := range ui.commandPanels {
+		existing[panel.command] = panel
 	}
 
-	panel.status.SetText(statusText)
-	panel.status.SetTextColor(statusColor)
+	panels := make([]*CommandPanel, 0, len(existing))
+	for _, cmd := range ui.executor.List() {
+		panel, exists := existing[cmd]
+		if !exists {
+			panel = ui.newPanel(cmd)
+		}
+		panels = append(panels, panel)
+	}
+	ui.commandPanels = panels
 
-	// Update output - use public methods instead of accessing private fields
-	output := strings.Join(panel.command.GetOutput(), "\n")
-	panel.output.SetText(output)
+	if ui.selectedPanel >= len(panels) {
+		ui.selectedPanel = 0
+	}
+	ui.arrangePanels()
+	ui.updatePanelSelection()
 }
 
-// expand expands the panel to full screen
-func (panel *CommandPanel) expand() {
-	// Create full screen view
-	fullScreen := tview.NewFlex().SetDirection(tview.FlexRow)
-
-	// Header with title and status
-	header := tview.NewFlex().SetDirection(tview.FlexColumn)
-	header.AddItem(panel.title, 0, 1, false)
-	header.AddItem(panel.status, 0, 1, false)
-
-	// Output area
-	outputArea := tview.NewTextView().
-		SetDynamicColors(true).
-		SetScrollable(true).
-		SetTextColor(tcell.ColorGreen)
-
-	// Set output text
-	output := strings.Join(panel.command.GetOutput(), "\n")
-	outputArea.SetText(output)
-
-	// Add components
-	fullScreen.AddItem(header, 3, 0, false)
-	fullScreen.AddItem(outputArea, 0, 1, true)
-
-	// Add close button
-	closeBtn := tview.NewButton("Close (ESC)").SetSelectedFunc(func() {
-		// Return to main view
-		// This would need to be implemented with proper navigation
+// newPanel creates the panel of a command; clicking it selects it
+func (ui *TUI) newPanel(command *executor.Command) *CommandPanel {
+	panel := NewCommandPanel(command).SetSelectedFunc(func(panel *CommandPanel) {
+		for i, other := range ui.commandPanels {
+			if other == panel {
+				ui.selectPanel(i)
+			}
+		}
 	})
+	panel.output.setTimestamps(ui.timestamps, ui.started)
+	return panel
+}
 
-	fullScreen.AddItem(closeBtn, 1, 0, false)
-
-	// Show full screen
-	// This would need proper navigation implementation
+// Run starts the TUI
+func (ui *TUI) Run() error {
+	return ui.app.Run()
 }
 
 // RunTUI starts the TUI application
 func RunTUI() error {
 	tui := NewTUI()
+
+	// Use the config in the working directory, if any
+	cfg, err := config.Load(config.DefaultFile)
+	if err == nil {
+		tui.LoadConfig(cfg)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
 	return tui.Run()
 }
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/pashkov256/cmdpool/internal/config"
+	"github.com/rivo/tview"
+)
+
+// zoomPage is the name of the page showing a single panel full screen
+const zoomPage = "zoom"
+
+// zoomView shows the output of one command full screen with scrollback
+type zoomView struct {
+	panel  *CommandPanel
+	layout *tview.Flex
+	header *tview.TextView
+	// shownHeader is the text of the header as last rendered
+	shownHeader string
+	// output follows the newest line; scrolling up pauses it
+	output  *outputView
+	helpBar *tview.TextView
+}
+
+// newZoomView builds the full screen view of a panel with the given help
+// bar text
+func newZoomView(panel *CommandPanel, help string) *zoomView {
+	z := &zoomView{
+		panel: panel,
+	}
+
+	z.header = tview.NewTextView().
+		SetDynamicColors(true)
+
+	z.output = newOutputView()
+
+	z.helpBar = tview.NewTextView().
+		SetTextAlign(tview.AlignCenter).
+		SetText(help).
+		SetTextColor(tcell.ColorGray)
+
+	z.layout = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(z.header, 1, 0, false).
+		AddItem(z.output, 0, 1, true).
+		AddItem(z.helpBar, 1, 0, false)
+	z.layout.SetBorder(true)
+	if panel.command.Color != "" {
+		z.layout.SetTitleColor(tcell.GetColor(panel.command.Color))
+	}
+
+	z.update()
+	return z
+}
+
+// update renders new output of the command and reports whether anything
+// changed
+func (z *zoomView) update() bool {
+	changed, _ := z.output.update(commandOutput{z.panel.command}, z.panel.search, z.panel.show)
+
+	mode := "[green]following[-]"
+	if !z.output.following() {
+		mode = "[yellow]paused[-]"
+	}
+	if header := z.panel.headerText() + " | " + mode; header != z.shownHeader {
+		z.shownHeader = header
+		z.header.SetText(header)
+		changed = true
+	}
+	if title := z.panel.titleText(); title != z.layout.GetTitle() {
+		z.layout.SetTitle(title)
+		changed = true
+	}
+	return changed
+}
+
+// toggleFollow switches between following the tail and a fixed position
+func (z *zoomView) toggleFollow() {
+	z.output.setFollow(!z.output.following())
+	z.update()
+}
+
+// toggleZoom shows the selected panel full screen, or returns to the panels
+func (ui *TUI) toggleZoom() {
+	if ui.zoom != nil {
+		ui.closeZoom()
+		return
+	}
+	if panel := ui.selectedCommandPanel(); panel != nil {
+		ui.openZoom(panel)
+	}
+}
+
+// openZoom shows a panel full screen
+func (ui *TUI) openZoom(panel *CommandPanel) {
+	// Looking at the output acknowledges its alerts
+	panel.command.AckAlerts()
+	ui.zoom = newZoomView(panel, ui.keys.helpBarText(config.KeyContextZoom))
+	ui.zoom.output.setTimestamps(ui.timestamps, ui.started)
+	ui.pages.AddPage(zoomPage, ui.zoom.layout, true, true)
+	ui.pages.HidePage("main")
+	ui.app.SetFocus(ui.zoom.output)
+}
+
+// closeZoom returns from the full screen view to the panels
+func (ui *TUI) closeZoom() {
+	if ui.zoom == nil {
+		return
+	}
+	// The search matches were counted for the zoomed output
+	ui.zoom.panel.output.invalidate()
+	ui.zoom = nil
+	ui.pages.RemovePage(zoomPage)
+	ui.pages.ShowPage("main")
+	ui.focusFront()
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -13,6 +14,7 @@ var (
 	configFile string
 	commandSet string
 	commands   []string
+	timestamps bool
 )
 
 // Run initializes and runs the CLI
@@ -27,29 +29,39 @@ Examples:
   cmdpool "ping google.com" "ping github.com"
   cmdpool -config .cmdpool.yml
   cmdpool -set backend`,
-		RunE: runCommands,
+		Args:          cobra.ArbitraryArgs,
+		RunE:          runCommands,
+		SilenceUsage:  true,
+		SilenceErrors: true,
 	}
 
+	rootCmd.AddCommand(newImportCmd())
+	rootCmd.AddCommand(newInitCmd())
+
 	// Add flags
 	rootCmd.Flags().StringVarP(&configFile, "config", "c", "", "Configuration file path")
 	rootCmd.Flags().StringVarP(&commandSet, "set", "s", "", "Command set name from config")
 	rootCmd.Flags().StringArrayVarP(&commands, "command", "e", []string{}, "Commands to execute")
+	rootCmd.Flags().BoolVarP(&timestamps, "timestamps", "t", false, "Print the time each output line was read")
 
 	return rootCmd.Execute()
 }
 
 func runCommands(cmd *cobra.Command, args []string) error {
-	var cmds []string
+	var entries []config.CommandEntry
+	var cfg *config.Config
+	var sets []string
 
 	// If commands provided via flags, use them
 	if len(commands) > 0 {
-		cmds = commands
+		entries = adHocEntries(commands)
 	} else if len(args) > 0 {
 		// If commands provided as arguments, use them
-		cmds = args
+		entries = adHocEntries(args)
 	} else if configFile != "" {
 		// Load from config file
-		cfg, err := config.Load(configFile)
+		var err error
+		cfg, err = config.Load(configFile)
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
@@ -60,24 +72,24 @@ func runCommands(cmd *cobra.Command, args []string) error {
 			if !exists {
 				return fmt.Errorf("command set '%s' not found", commandSet)
 			}
-			cmds = set.Commands
+			sets = []string{commandSet}
+			entries = set.Entries(commandSet)
 		} else {
 			// Run all commands from config
-			for _, set := range cfg.CommandSets {
-				cmds = append(cmds, set.Commands...)
-			}
+			sets = cfg.SetNames()
+			entries = cfg.Entries()
 		}
 	} else {
 		return fmt.Errorf("no commands specified. Use -e flag, provide arguments, or use -config")
 	}
 
-	if len(cmds) == 0 {
+	if len(entries) == 0 {
 		return fmt.Errorf("no commands to execute")
 	}
 
-	fmt.Printf("Starting %d commands...\n", len(cmds))
-	for i, cmdStr := range cmds {
-		fmt.Printf("[%d] %s\n", i+1, cmdStr)
+	fmt.Printf("Starting %d commands...\n", len(entries))
+	for i, entry := range entries {
+		fmt.Printf("[%d] %s: %s\n", i+1, entry.Name, entry.Run)
 	}
 	fmt.Println()
 
@@ -85,16 +97,77 @@ func runCommands(cmd *cobra.Command, args []string) error {
 	exec := executor.NewExecutor()
 
 	// Start commands
-	for i, cmdStr := range cmds {
-		go exec.RunCommand(fmt.Sprintf("cmd_%d", i), cmdStr, ".", false)
+	if cfg != nil {
+		exec.SetHooks(cfg.Global.Hooks)
+		exec.SetMaxLineLength(cfg.Global.MaxLineLength)
+		exec.SetOutputLimits(cfg.Global.MaxOutput, cfg.Global.MaxOutputBytes, cfg.Global.SpillOutput)
+		exec.SetAlerts(cfg.Alerts)
+		exec.SetMasking(cfg.Global.Mask)
+		for _, key := range sets {
+			exec.StartSet(key, cfg.CommandSets[key])
+		}
+		go watchConfig(exec, cfg)
+	} else {
+		for _, entry := range entries {
+			exec.Start(entry)
+		}
 	}
 
 	// Monitor and display output
-	return monitorCommands(exec, cmds)
+	return monitorCommands(exec)
+}
+
+// watchConfig reloads the config whenever one of its files changes and
+// restarts only the commands whose definition changed
+func watchConfig(exec *executor.Executor, cfg *config.Config) {
+	current := selectSet(cfg)
+	cfg.Watch(context.Background(), 500*time.Millisecond, func(next *config.Config, err error) {
+		if err != nil {
+			fmt.Printf("\n[cmdpool] config not reloaded: %v\n", err)
+			return
+		}
+
+		next = selectSet(next)
+		diff := exec.Reload(current, next)
+		current = next
+		fmt.Printf("\n[cmdpool] config reloaded: %s\n", diff.Summary())
+	})
+}
+
+// selectSet restricts a config to the set chosen with --set, if any
+func selectSet(cfg *config.Config) *config.Config {
+	if commandSet == "" {
+		return cfg
+	}
+
+	selected := *cfg
+	selected.CommandSets = make(map[string]config.CommandSet)
+	if set, exists := cfg.CommandSets[commandSet]; exists {
+		selected.CommandSets[commandSet] = set
+	}
+	return &selected
+}
+
+// adHocEntries builds command entries from command lines given on the
+// command line, using the command line itself as the name
+func adHocEntries(cmds []string) []config.CommandEntry {
+	entries := make([]config.CommandEntry, 0, len(cmds))
+	seen := make(map[string]int)
+
+	for _, cmdStr := range cmds {
+		name := cmdStr
+		seen[cmdStr]++
+		if seen[cmdStr] > 1 {
+			name = fmt.Sprintf("%s #%d", cmdStr, seen[cmdStr])
+		}
+		entries = append(entries, config.CommandEntry{Name: name, Run: cmdStr, Dir: "."})
+	}
+
+	return entries
 }
 
 // monitorCommands monitors running commands and displays their output
-func monitorCommands(exec *executor.Executor, commands []string) error {
+func monitorCommands(exec *executor.Executor) error {
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
@@ -104,18 +177,21 @@ func monitorCommands(exec *executor.Executor, commands []string) error {
 	for {
 		select {
 		case <-ticker.C:
-			cmds := exec.GetCommands()
+			cmds := exec.List()
 
 			// Check if all commands are completed
 			allCompleted := true
 			for _, cmd := range cmds {
-				if cmd.Status != executor.StatusDone && cmd.Status != executor.StatusFailed && cmd.Status != executor.StatusStopped {
+				if !cmd.GetStatus().Finished() {
 					allCompleted = false
 					break
 				}
 			}
 
 			if allCompleted && len(cmds) > 0 {
+				// Let the hooks run after the commands finish
+				exec.Wait()
+
 				// Show final results
 				fmt.Println("\n=== Final Results ===")
 				for _, cmd := range cmds {
@@ -124,6 +200,8 @@ func monitorCommands(exec *executor.Executor, commands []string) error {
 						status = "🔴"
 					} else if cmd.Status == executor.StatusStopped {
 						status = "⏹️"
+					} else if cmd.Status == executor.StatusSkipped {
+						status = "⏭️"
 					}
 					fmt.Printf("%s %s: %s\n", status, cmd.ID, cmd.Status)
 					if cmd.Error != nil {
@@ -145,12 +223,12 @@ func monitorCommands(exec *executor.Executor, commands []string) error {
 							start = len(output) - 5
 						}
 						for _, line := range output[start:] {
-							fmt.Printf("  %s\n", line)
+							fmt.Printf("  %s\n", formatLine(line))
 						}
 					}
 
 					// Mark as completed if done
-					if cmd.Status == executor.StatusDone || cmd.Status == executor.StatusFailed || cmd.Status == executor.StatusStopped {
+					if cmd.GetStatus().Finished() {
 						completed[cmd.ID] = true
 					}
 				}
@@ -158,3 +236,19 @@ func monitorCommands(exec *executor.Executor, commands []string) error {
 		}
 	}
 }
+
+// formatLine writes an output line for the terminal, marking stderr and
+// the level of log lines and prefixing the time the line was read when --timestamps is set
+func formatLine(line executor.OutputLine) string {
+	text := line.Text
+	if line.Level != executor.LevelNone {
+		text = fmt.Sprintf("%-5s %s", line.Level, text)
+	}
+	if line.Stream == executor.StreamStderr {
+		text = "[stderr] " + text
+	}
+	if timestamps {
+		text = line.Time.Format(executor.TimeFormat) + " " + text
+	}
+	return text
+}
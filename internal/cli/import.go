@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importType   string
+	importOnly   []string
+	importPrefix string
+	importOutput string
+	importMerge  bool
+)
+
+// newImportCmd creates the import subcommand
+func newImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import <file>...",
+		Short: "Convert a Procfile, docker-compose file, package.json or Makefile into a cmdpool config",
+		Long: `Import converts process definitions from other formats into command sets
+and writes them to a cmdpool config file.
+
+Supported formats (detected from the file name unless --type is given):
+  procfile   Procfile processes
+  compose    services of a docker-compose file
+  npm        scripts of a package.json
+  make       Makefile targets (select them with --only)
+
+Examples:
+  cmdpool import Procfile
+  cmdpool import docker-compose.yml frontend/package.json --merge
+  cmdpool import Makefile --only build,test --prefix make:`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runImport,
+	}
+
+	cmd.Flags().StringVarP(&importType, "type", "t", "", "Source type: procfile, compose, npm or make")
+	cmd.Flags().StringSliceVar(&importOnly, "only", nil, "Only import these processes, services, scripts or targets")
+	cmd.Flags().StringVar(&importPrefix, "prefix", "", "Prefix for imported command set and command names")
+	cmd.Flags().StringVarP(&importOutput, "output", "o", config.DefaultFile, "Config file to write")
+	cmd.Flags().BoolVar(&importMerge, "merge", false, "Add to an existing config file instead of refusing to overwrite it")
+
+	return cmd
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	cfg := config.DefaultConfig()
+	cfg.CommandSets = make(map[string]config.CommandSet)
+
+	merge := false
+	if _, err := os.Stat(importOutput); err == nil {
+		if !importMerge {
+			return fmt.Errorf("%s already exists, use --merge to add to it", importOutput)
+		}
+		if cfg, err = config.Load(importOutput); err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		merge = true
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var added []string
+	imported := make(map[string]config.CommandSet)
+	for _, path := range args {
+		sets, err := config.Import(config.Source{
+			Type:   importType,
+			Path:   path,
+			Only:   importOnly,
+			Prefix: importPrefix,
+		})
+		if err != nil {
+			return err
+		}
+
+		for key, set := range sets {
+			if _, exists := cfg.CommandSets[key]; exists {
+				return fmt.Errorf("command set '%s' from %s already exists in %s", key, path, importOutput)
+			}
+			cfg.CommandSets[key] = set
+			imported[key] = set
+			added = append(added, key)
+		}
+	}
+
+	if len(added) == 0 {
+		return fmt.Errorf("nothing to import")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	// Merging only adds the new sets to the file, so its comments and the
+	// way it is written stay as they are
+	if merge {
+		if err := config.AddCommandSets(importOutput, imported); err != nil {
+			return err
+		}
+	} else if err := cfg.Save(importOutput); err != nil {
+		return err
+	}
+
+	sort.Strings(added)
+	fmt.Printf("Imported %d command sets into %s:\n", len(added), importOutput)
+	for _, key := range added {
+		fmt.Printf("  %s: %s\n", key, cfg.CommandSets[key].Commands[0].Run)
+	}
+	return nil
+}
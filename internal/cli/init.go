@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	initYes    bool
+	initOutput string
+)
+
+// commonTargets are the Makefile targets and npm scripts proposed by init
+var commonTargets = []string{"dev", "start", "serve", "run", "watch", "build", "test", "lint"}
+
+// proposal is a command set suggested by init
+type proposal struct {
+	key    string
+	set    config.CommandSet
+	source string
+}
+
+// newInitCmd creates the init subcommand
+func newInitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create a starter .cmdpool.yml for the current project",
+		Long: `Init inspects the working directory (go.mod, package.json, docker-compose.yml,
+Makefile, Procfile), proposes command sets and asks which ones to keep.
+An existing config file is never overwritten.`,
+		Args: cobra.NoArgs,
+		RunE: runInit,
+	}
+
+	cmd.Flags().BoolVarP(&initYes, "yes", "y", false, "Accept every proposed command set without asking")
+	cmd.Flags().StringVarP(&initOutput, "output", "o", config.DefaultFile, "Config file to write")
+
+	return cmd
+}
+
+func runInit(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(initOutput); err == nil {
+		return fmt.Errorf("%s already exists, remove it or choose another file with --output", initOutput)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	proposals := detectProposals(".")
+	out := cmd.OutOrStdout()
+
+	accepted := proposals
+	if !initYes && len(proposals) > 0 {
+		var err error
+		if accepted, err = askProposals(cmd.InOrStdin(), out, proposals); err != nil {
+			return err
+		}
+	}
+
+	accepted = pruneDependencies(accepted)
+
+	cfg := config.DefaultConfig()
+	comments := map[string]string{
+		"example": "No project files were detected, replace this example with your own commands",
+	}
+	if len(accepted) > 0 {
+		cfg.CommandSets = make(map[string]config.CommandSet)
+		comments = make(map[string]string)
+		for _, p := range accepted {
+			cfg.CommandSets[p.key] = p.set
+			comments[p.key] = "Detected from " + p.source
+		}
+	}
+
+	header := `cmdpool configuration, generated by "cmdpool init".
+Each command is either a command line or a mapping with name, run, dir,
+env, restart, depends_on, probe and color. Run "cmdpool -c ` + initOutput + `" to start.`
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("generated config is invalid: %w", err)
+	}
+	if err := cfg.SaveWithComments(initOutput, header, comments); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Wrote %s with %d command sets\n", initOutput, len(cfg.CommandSets))
+	return nil
+}
+
+// askProposals asks the user to accept or reject each proposal
+func askProposals(in io.Reader, out io.Writer, proposals []proposal) ([]proposal, error) {
+	reader := bufio.NewReader(in)
+	var accepted []proposal
+
+	for _, p := range proposals {
+		fmt.Fprintf(out, "Add %q (%s) from %s? [Y/n] ", p.key, p.set.Commands[0].Run, p.source)
+		answer, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "", "y", "yes":
+			accepted = append(accepted, p)
+		}
+
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(out)
+			break
+		}
+	}
+
+	return accepted, nil
+}
+
+// pruneDependencies drops the depends_on entries that refer to commands of
+// proposals that were not accepted
+func pruneDependencies(proposals []proposal) []proposal {
+	names := make(map[string]bool)
+	for _, p := range proposals {
+		for _, entry := range p.set.Entries(p.key) {
+			names[entry.Name] = true
+		}
+	}
+
+	result := make([]proposal, len(proposals))
+	for i, p := range proposals {
+		commands := make([]config.CommandEntry, len(p.set.Commands))
+		for j, entry := range p.set.Commands {
+			var deps []string
+			for _, dep := range entry.DependsOn {
+				if names[dep] {
+					deps = append(deps, dep)
+				}
+			}
+			entry.DependsOn = deps
+			commands[j] = entry
+		}
+		p.set.Commands = commands
+		result[i] = p
+	}
+	return result
+}
+
+// detectProposals inspects dir for known project files and proposes
+// command sets for them
+func detectProposals(dir string) []proposal {
+	var proposals []proposal
+	seen := make(map[string]bool)
+
+	add := func(source string, sets map[string]config.CommandSet) {
+		keys := make([]string, 0, len(sets))
+		for key := range sets {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			proposals = append(proposals, proposal{key: key, set: sets[key], source: source})
+		}
+	}
+
+	if exists(filepath.Join(dir, "go.mod")) {
+		sets := map[string]config.CommandSet{
+			"go:build": goSet("go:build", "go build ./...", dir),
+			"go:test":  goSet("go:test", "go test ./...", dir),
+		}
+		if exists(filepath.Join(dir, "main.go")) {
+			sets["go:run"] = goSet("go:run", "go run .", dir)
+		}
+		add("go.mod", sets)
+	}
+
+	for _, name := range []string{"Procfile", "docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"} {
+		path := filepath.Join(dir, name)
+		if !exists(path) {
+			continue
+		}
+		if sets, err := config.Import(config.Source{Path: path}); err == nil {
+			add(name, sets)
+		}
+	}
+
+	if path := filepath.Join(dir, "package.json"); exists(path) {
+		if sets, err := config.Import(config.Source{Path: path, Prefix: "npm:"}); err == nil {
+			add("package.json", commonOnly(sets, "npm:"))
+		}
+	}
+
+	if path := filepath.Join(dir, "Makefile"); exists(path) {
+		if sets, err := config.Import(config.Source{Path: path, Prefix: "make:"}); err == nil {
+			add("Makefile", commonOnly(sets, "make:"))
+		}
+	}
+
+	return proposals
+}
+
+// commonOnly keeps the sets named after common targets, or all of them if
+// none match
+func commonOnly(sets map[string]config.CommandSet, prefix string) map[string]config.CommandSet {
+	result := make(map[string]config.CommandSet)
+	for _, target := range commonTargets {
+		if set, exists := sets[prefix+target]; exists {
+			result[prefix+target] = set
+		}
+	}
+	if len(result) == 0 {
+		return sets
+	}
+	return result
+}
+
+// goSet builds a command set for a go tool invocation
+func goSet(name, run, dir string) config.CommandSet {
+	return config.CommandSet{
+		Name:     name,
+		Commands: []config.CommandEntry{{Name: name, Run: run}},
+		Dir:      dir,
+	}
+}
+
+// exists reports whether a file exists
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+)
+
+// writeFiles creates files with the given contents in dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// proposalKeys returns the keys of proposals in order
+func proposalKeys(proposals []proposal) []string {
+	var keys []string
+	for _, p := range proposals {
+		keys = append(keys, p.key)
+	}
+	return keys
+}
+
+func TestDetectProposals(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{"nothing", nil, nil},
+		{"go module", map[string]string{"go.mod": "module x\n"}, []string{"go:build", "go:test"}},
+		{"go program", map[string]string{"go.mod": "module x\n", "main.go": "package main\n"}, []string{"go:build", "go:run", "go:test"}},
+		{"procfile", map[string]string{"Procfile": "web: ./web\nworker: ./worker\n"}, []string{"web", "worker"}},
+		{"common npm scripts only", map[string]string{"package.json": `{"scripts": {"dev": "vite", "test": "vitest", "format": "prettier"}}`}, []string{"npm:dev", "npm:test"}},
+		{"every make target without common ones", map[string]string{"Makefile": "deploy:\n\t./deploy\nclean:\n\trm -rf out\n"}, []string{"make:clean", "make:deploy"}},
+		{"first source wins", map[string]string{"Procfile": "web: ./web\n", "compose.yml": "services:\n  web:\n    image: nginx\n  db:\n    image: postgres\n"}, []string{"web", "db"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			if got := proposalKeys(detectProposals(dir)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("proposals = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAskProposals(t *testing.T) {
+	proposals := []proposal{
+		{key: "a", set: config.CommandSet{Commands: []config.CommandEntry{{Run: "run a"}}}, source: "Procfile"},
+		{key: "b", set: config.CommandSet{Commands: []config.CommandEntry{{Run: "run b"}}}, source: "Procfile"},
+		{key: "c", set: config.CommandSet{Commands: []config.CommandEntry{{Run: "run c"}}}, source: "Procfile"},
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"accept by default", "\n\n\n", []string{"a", "b", "c"}},
+		{"yes and no", "y\nn\nYES\n", []string{"a", "c"}},
+		{"anything else rejects", "nope\n\ny\n", []string{"b", "c"}},
+		{"end of input accepts the last answer", "n\ny", []string{"b"}},
+		{"no input", "", []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			accepted, err := askProposals(strings.NewReader(tt.input), &out, proposals)
+			if err != nil {
+				t.Fatalf("askProposals() error = %v", err)
+			}
+			if got := proposalKeys(accepted); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("accepted = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneDependencies(t *testing.T) {
+	proposals := []proposal{
+		{key: "web", set: config.CommandSet{Commands: []config.CommandEntry{{Name: "web", Run: "./web", DependsOn: []string{"db", "cache"}}}}},
+		{key: "db", set: config.CommandSet{Commands: []config.CommandEntry{{Run: "postgres"}}}},
+	}
+
+	pruned := pruneDependencies(proposals)
+	if got, want := pruned[0].set.Commands[0].DependsOn, []string{"db"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("depends_on = %q, want %q", got, want)
+	}
+	if got, want := proposals[0].set.Commands[0].DependsOn, []string{"db", "cache"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("original depends_on = %q, want %q unchanged", got, want)
+	}
+
+	cfg := &config.Config{CommandSets: map[string]config.CommandSet{}}
+	for _, p := range pruned {
+		cfg.CommandSets[p.key] = p.set
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// AlertSeverity is how serious a match of an alert rule is
+type AlertSeverity string
+
+const (
+	SeverityWarn  AlertSeverity = "warn"
+	SeverityError AlertSeverity = "error"
+)
+
+// AlertsConfig configures the rules output lines are checked against. The
+// built-in rules apply unless Defaults is false.
+type AlertsConfig struct {
+	Defaults *bool       `yaml:"defaults,omitempty"`
+	Rules    []AlertRule `yaml:"rules,omitempty"`
+}
+
+// AlertRule raises an alert on the command when one of its output lines
+// matches Pattern. Each line raises at most one alert, for the first rule
+// it matches.
+type AlertRule struct {
+	Name     string        `yaml:"name,omitempty"`
+	Pattern  string        `yaml:"pattern"`
+	Severity AlertSeverity `yaml:"severity,omitempty"`
+	// Commands limits the rule to the commands with these names
+	Commands []string `yaml:"commands,omitempty"`
+	// Hook is run for a match, at most every few seconds per command
+	Hook string `yaml:"hook,omitempty"`
+	// Bell rings the terminal bell on a match
+	Bell bool `yaml:"bell,omitempty"`
+}
+
+// DefaultAlertRules are the built-in rules: Go panics, uncaught Node.js
+// errors, compiler errors and error lines of plain text logs
+var DefaultAlertRules = []AlertRule{
+	{Name: "go panic", Pattern: `^(panic: |fatal error: )`, Severity: SeverityError},
+	{Name: "node error", Pattern: `^\s*(Uncaught |Unhandled )?[A-Z]\w*Error( \[\w+\])?: |UnhandledPromiseRejection`, Severity: SeverityError},
+	{Name: "go compile error", Pattern: `^\S+\.go:\d+:\d+: `, Severity: SeverityError},
+	{Name: "compile error", Pattern: `(: (fatal )?error( TS\d+)?: |^error(\[E\d+\])?: )`, Severity: SeverityError},
+	{Name: "error log", Pattern: `\b(ERROR|FATAL)\b`, Severity: SeverityError},
+}
+
+// AllRules returns the configured rules, after the built-in ones unless
+// they are turned off. A nil config has only the built-in rules.
+func (a *AlertsConfig) AllRules() []AlertRule {
+	if a == nil {
+		return DefaultAlertRules
+	}
+
+	var rules []AlertRule
+	if a.Defaults == nil || *a.Defaults {
+		rules = append(rules, DefaultAlertRules...)
+	}
+	return append(rules, a.Rules...)
+}
+
+// validate checks the rules against the command names of the config
+func (a *AlertsConfig) validate(commands map[string]string) error {
+	for i, rule := range a.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil || rule.Pattern == "" {
+			return fmt.Errorf("alert rule %s has an invalid pattern %q", name, rule.Pattern)
+		}
+		switch rule.Severity {
+		case "", SeverityWarn, SeverityError:
+		default:
+			return fmt.Errorf("alert rule %s has unknown severity %q", name, rule.Severity)
+		}
+		for _, command := range rule.Commands {
+			if _, exists := commands[command]; !exists {
+				return fmt.Errorf("alert rule %s refers to unknown command %q", name, command)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RestartPolicy controls whether a command is restarted after it exits
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "never"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartAlways    RestartPolicy = "always"
+)
+
+// CommandEntry represents a single command inside a command set.
+// In YAML it can be written either as a plain string (the command line)
+// or as a mapping with the fields below.
+type CommandEntry struct {
+	Name      string        `yaml:"name,omitempty"`
+	Run       string        `yaml:"run"`
+	Dir       string        `yaml:"dir,omitempty"`
+	Env       []string      `yaml:"env,omitempty"`
+	Restart   RestartPolicy `yaml:"restart,omitempty"`
+	DependsOn []string      `yaml:"depends_on,omitempty"`
+	Probe     string        `yaml:"probe,omitempty"`
+	Color     string        `yaml:"color,omitempty"`
+	Watch     *WatchConfig  `yaml:"watch,omitempty"`
+	Schedule  string        `yaml:"schedule,omitempty"`
+	Every     time.Duration `yaml:"every,omitempty"`
+	Overlap   OverlapPolicy `yaml:"overlap,omitempty"`
+	Hooks     *Hooks        `yaml:"hooks,omitempty"`
+	Pty       bool          `yaml:"pty,omitempty"`
+	Format    LogFormat     `yaml:"format,omitempty"`
+}
+
+// LogFormat is how the output lines of a command are parsed as structured
+// logs
+type LogFormat string
+
+const (
+	// FormatJSON parses lines that are JSON objects
+	FormatJSON LogFormat = "json"
+	// FormatLogfmt parses key=value lines
+	FormatLogfmt LogFormat = "logfmt"
+	// FormatAuto parses JSON and logfmt lines and leaves other lines as
+	// they are
+	FormatAuto LogFormat = "auto"
+)
+
+// OverlapPolicy controls what happens when a scheduled command is due
+// while its previous run is still active
+type OverlapPolicy string
+
+const (
+	// OverlapSkip drops the new run (the default)
+	OverlapSkip OverlapPolicy = "skip"
+	// OverlapQueue starts the new run as soon as the previous one ends
+	OverlapQueue OverlapPolicy = "queue"
+	// OverlapReplace stops the previous run and starts the new one
+	OverlapReplace OverlapPolicy = "replace"
+)
+
+// Scheduled reports whether the entry runs on a schedule instead of once
+func (e CommandEntry) Scheduled() bool {
+	return e.Schedule != "" || e.Every > 0
+}
+
+// WatchConfig restarts a command when files below its directory change
+type WatchConfig struct {
+	Paths    []string      `yaml:"paths,omitempty"`
+	Include  []string      `yaml:"include,omitempty"`
+	Exclude  []string      `yaml:"exclude,omitempty"`
+	Debounce time.Duration `yaml:"debounce,omitempty"`
+	Action   WatchAction   `yaml:"action,omitempty"`
+}
+
+// WatchAction is what happens to a command when a watched file changes:
+// "restart" (the default) stops and starts it, "rerun" starts it again
+// once the current run has finished, and "signal: NAME" sends a signal.
+type WatchAction string
+
+const (
+	WatchRestart WatchAction = "restart"
+	WatchRerun   WatchAction = "rerun"
+)
+
+// UnmarshalYAML accepts a string or a {signal: NAME} mapping
+func (a *WatchAction) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*a = WatchAction(value.Value)
+		return nil
+	}
+
+	var action struct {
+		Signal string `yaml:"signal"`
+	}
+	if err := value.Decode(&action); err != nil {
+		return err
+	}
+	*a = WatchAction("signal: " + action.Signal)
+	return nil
+}
+
+// Signal returns the signal name of a "signal: NAME" action
+func (a WatchAction) Signal() (string, bool) {
+	name, found := strings.CutPrefix(string(a), "signal:")
+	if !found {
+		return "", false
+	}
+	return strings.ToUpper(strings.TrimSpace(name)), true
+}
+
+// UnmarshalYAML accepts either a string or a mapping
+func (e *CommandEntry) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*e = CommandEntry{Run: value.Value}
+		return nil
+	}
+
+	// Use an alias type to avoid recursing into this method
+	type plain CommandEntry
+	var entry plain
+	if err := value.Decode(&entry); err != nil {
+		return err
+	}
+
+	*e = CommandEntry(entry)
+	return nil
+}
+
+// MarshalYAML writes entries that only carry a command line as a plain string
+func (e CommandEntry) MarshalYAML() (interface{}, error) {
+	type plain CommandEntry
+	if reflect.DeepEqual(e, CommandEntry{Run: e.Run}) {
+		return e.Run, nil
+	}
+	return plain(e), nil
+}
+
+// Environment returns the variables of the set, those read from its env
+// file first so the ones in Env override them
+func (s CommandSet) Environment() []string {
+	env := make([]string, 0, len(s.fileEnv)+len(s.Env))
+	env = append(env, s.fileEnv...)
+	return append(env, s.Env...)
+}
+
+// Entries returns the commands of the set with the set's fields applied
+// as defaults. key is the name of the set in the config file and is used
+// to derive names for entries that do not define one.
+func (s CommandSet) Entries(key string) []CommandEntry {
+	base := s.Name
+	if base == "" {
+		base = key
+	}
+
+	restart := RestartNever
+	if s.AutoRestart {
+		restart = RestartOnFailure
+	}
+
+	entries := make([]CommandEntry, 0, len(s.Commands))
+	for i, entry := range s.Commands {
+		if entry.Name == "" {
+			if len(s.Commands) == 1 {
+				entry.Name = base
+			} else {
+				entry.Name = fmt.Sprintf("%s %d", base, i+1)
+			}
+		}
+
+		switch {
+		case entry.Dir == "":
+			entry.Dir = s.Dir
+		case !filepath.IsAbs(entry.Dir) && s.Dir != "":
+			entry.Dir = filepath.Join(s.Dir, entry.Dir)
+		}
+		if entry.Dir == "" {
+			entry.Dir = "."
+		}
+
+		// Set env comes first so entry variables override it
+		entry.Env = append(s.Environment(), entry.Env...)
+
+		if entry.Restart == "" {
+			entry.Restart = restart
+		}
+
+		if entry.Watch == nil {
+			entry.Watch = s.Watch
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
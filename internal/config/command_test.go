@@ -0,0 +1,49 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestWatchAction(t *testing.T) {
+	tests := []struct {
+		name   string
+		yaml   string
+		signal string
+		err    string
+	}{
+		{"default", "{}", "", ""},
+		{"restart", "action: restart", "", ""},
+		{"rerun", "action: rerun", "", ""},
+		{"signal mapping", "action: {signal: SIGKILL}", "SIGKILL", ""},
+		{"signal string", `action: "signal: sigkill"`, "SIGKILL", ""},
+		{"unknown action", "action: reload", "", `unknown watch action "reload"`},
+		{"unknown signal", "action: {signal: SIGFOO}", "SIGFOO", `unsupported signal "SIGFOO"`},
+		{"empty signal", "action: {signal: ''}", "", `unsupported signal ""`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var watch WatchConfig
+			if err := yaml.Unmarshal([]byte(tt.yaml), &watch); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if signal, _ := watch.Action.Signal(); signal != tt.signal {
+				t.Errorf("Signal() = %q, want %q", signal, tt.signal)
+			}
+
+			cfg := &Config{CommandSets: map[string]CommandSet{
+				"app": {Commands: []CommandEntry{{Run: "./app", Watch: &watch}}},
+			}}
+			err := cfg.Validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("Validate() error = %v, want none", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("Validate() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -4,31 +4,79 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
+// DefaultFile is the configuration file looked up in the working directory
+const DefaultFile = ".cmdpool.yml"
+
 // Config represents the main configuration structure
 type Config struct {
 	CommandSets map[string]CommandSet `yaml:"commands"`
+	Sources     []Source              `yaml:"sources,omitempty"`
 	Global      GlobalConfig          `yaml:"global"`
+	Layout      *LayoutConfig         `yaml:"layout,omitempty"`
+	Keys        *KeysConfig           `yaml:"keys,omitempty"`
+	Alerts      *AlertsConfig         `yaml:"alerts,omitempty"`
+
+	// imported marks command sets that came from Sources
+	imported map[string]bool
+	// files lists the files the config was loaded from
+	files []string
 }
 
 // CommandSet represents a group of related commands
 type CommandSet struct {
-	Name        string   `yaml:"name"`
-	Description string   `yaml:"description"`
-	Commands    []string `yaml:"commands"`
-	Dir         string   `yaml:"dir"`
-	AutoRestart bool     `yaml:"auto_restart"`
-	Env         []string `yaml:"env"`
+	Name            string         `yaml:"name"`
+	Description     string         `yaml:"description,omitempty"`
+	Commands        []CommandEntry `yaml:"commands"`
+	Dir             string         `yaml:"dir,omitempty"`
+	AutoRestart     bool           `yaml:"auto_restart,omitempty"`
+	Env             []string       `yaml:"env,omitempty"`
+	Mode            RunMode        `yaml:"mode,omitempty"`
+	ContinueOnError bool           `yaml:"continue_on_error,omitempty"`
+	EnvFile         string         `yaml:"env_file,omitempty"`
+	Watch           *WatchConfig   `yaml:"watch,omitempty"`
+	Hooks           *Hooks         `yaml:"hooks,omitempty"`
+
+	// fileEnv holds the variables read from EnvFile
+	fileEnv []string
 }
 
+// RunMode controls how the commands of a set are run
+type RunMode string
+
+const (
+	// ModeParallel runs all commands at once
+	ModeParallel RunMode = "parallel"
+	// ModeSequential runs commands one after another, stopping on the
+	// first failure unless ContinueOnError is set
+	ModeSequential RunMode = "sequential"
+	// ModeRace runs all commands at once and stops the others as soon
+	// as the first one finishes
+	ModeRace RunMode = "race"
+)
+
 // GlobalConfig represents global settings
 type GlobalConfig struct {
 	LogFile     string `yaml:"log_file"`
 	MaxOutput   int    `yaml:"max_output_lines"`
 	RefreshRate int    `yaml:"refresh_rate_ms"`
+	Hooks       *Hooks `yaml:"hooks,omitempty"`
+	// MaxOutputBytes bounds the output text each command keeps in memory
+	// next to MaxOutput
+	MaxOutputBytes int `yaml:"max_output_bytes,omitempty"`
+	// SpillOutput moves output pushed out of memory to a temporary file,
+	// so the whole history can still be scrolled through
+	SpillOutput bool `yaml:"spill_output,omitempty"`
+	// MaxLineLength is the longest output line kept, in bytes; longer
+	// lines are truncated
+	MaxLineLength int `yaml:"max_line_length,omitempty"`
+	// Mask hides secrets in output before it is stored
+	Mask *MaskConfig `yaml:"mask,omitempty"`
 }
 
 // Load loads configuration from a file
@@ -51,12 +99,423 @@ func Load(filename string) (*Config, error) {
 		config.Global.MaxOutput = 1000
 	}
 
+	config.files = []string{filename}
+
+	if err := config.loadSources(filename); err != nil {
+		return nil, err
+	}
+
+	if err := config.loadEnvFiles(filename); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+
 	return &config, nil
 }
 
+// Files returns the config file and every file it includes (sources and
+// env files), as resolved when the config was loaded
+func (c *Config) Files() []string {
+	files := make([]string, len(c.files))
+	copy(files, c.files)
+	return files
+}
+
+// loadEnvFiles reads the env files of all command sets
+func (c *Config) loadEnvFiles(filename string) error {
+	for key, set := range c.CommandSets {
+		if set.EnvFile == "" {
+			continue
+		}
+
+		path := set.EnvFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(filename), path)
+		}
+
+		env, err := readEnvFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read env file of %q: %w", key, err)
+		}
+
+		set.fileEnv = env
+		c.CommandSets[key] = set
+		c.files = append(c.files, path)
+	}
+	return nil
+}
+
+// readEnvFile parses KEY=value lines, ignoring blank lines and comments
+func readEnvFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var env []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env = append(env, strings.TrimSpace(key)+"="+value)
+	}
+	return env, nil
+}
+
+// Validate checks that command names are unique and references resolve
+func (c *Config) Validate() error {
+	if c.Global.MaxLineLength < 0 {
+		return fmt.Errorf("max_line_length must not be negative")
+	}
+	if c.Global.MaxOutput < 0 || c.Global.MaxOutputBytes < 0 {
+		return fmt.Errorf("output limits must not be negative")
+	}
+	if c.Global.Mask != nil {
+		if err := c.Global.Mask.validate(); err != nil {
+			return err
+		}
+	}
+
+	names := make(map[string]string)
+	for _, key := range c.SetNames() {
+		set := c.CommandSets[key]
+		switch set.Mode {
+		case "", ModeParallel, ModeSequential, ModeRace:
+		default:
+			return fmt.Errorf("command set %q has unknown mode %q", key, set.Mode)
+		}
+		if len(set.Commands) == 0 {
+			return fmt.Errorf("command set %q has no commands", key)
+		}
+
+		for _, entry := range set.Entries(key) {
+			if entry.Run == "" {
+				return fmt.Errorf("command %q in set %q has nothing to run", entry.Name, key)
+			}
+			if other, exists := names[entry.Name]; exists {
+				return fmt.Errorf("command name %q is used in both %q and %q", entry.Name, other, key)
+			}
+			names[entry.Name] = key
+
+			switch entry.Restart {
+			case RestartNever, RestartOnFailure, RestartAlways:
+			default:
+				return fmt.Errorf("command %q has unknown restart policy %q", entry.Name, entry.Restart)
+			}
+
+			switch entry.Format {
+			case "", FormatJSON, FormatLogfmt, FormatAuto:
+			default:
+				return fmt.Errorf("command %q has unknown format %q", entry.Name, entry.Format)
+			}
+
+			if entry.Schedule != "" && entry.Every > 0 {
+				return fmt.Errorf("command %q sets both schedule and every", entry.Name)
+			}
+			if entry.Schedule != "" {
+				if _, err := ParseCron(entry.Schedule); err != nil {
+					return fmt.Errorf("command %q: %w", entry.Name, err)
+				}
+			}
+			// A scheduled command never finishes, so later steps would wait forever
+			if entry.Scheduled() && set.Mode == ModeSequential {
+				return fmt.Errorf("command %q is scheduled and cannot be a step of sequential set %q", entry.Name, key)
+			}
+			switch entry.Overlap {
+			case "", OverlapSkip, OverlapQueue, OverlapReplace:
+			default:
+				return fmt.Errorf("command %q has unknown overlap policy %q", entry.Name, entry.Overlap)
+			}
+
+			if entry.Watch != nil {
+				if name, isSignal := entry.Watch.Action.Signal(); isSignal {
+					if _, known := Signals[name]; !known {
+						return fmt.Errorf("command %q watches with unsupported signal %q", entry.Name, name)
+					}
+				} else {
+					switch entry.Watch.Action {
+					case "", WatchRestart, WatchRerun:
+					default:
+						return fmt.Errorf("command %q has unknown watch action %q", entry.Name, entry.Watch.Action)
+					}
+				}
+			}
+		}
+	}
+
+	deps := make(map[string][]string)
+	for _, entry := range c.Entries() {
+		for _, dep := range entry.DependsOn {
+			if _, exists := names[dep]; !exists {
+				return fmt.Errorf("command %q depends on unknown command %q", entry.Name, dep)
+			}
+		}
+		deps[entry.Name] = entry.DependsOn
+	}
+	if cycle := dependencyCycle(deps); cycle != nil {
+		return fmt.Errorf("depends_on forms a cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	if c.Layout != nil {
+		if err := c.Layout.validate(names, c.CommandSets); err != nil {
+			return err
+		}
+	}
+
+	if c.Keys != nil {
+		if err := c.Keys.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Alerts != nil {
+		if err := c.Alerts.validate(names); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dependencyCycle returns the names along a cycle of deps, starting and
+// ending with the same command, or nil if there is none
+func dependencyCycle(deps map[string][]string) []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			for i, other := range path {
+				if other == name {
+					return append(append([]string(nil), path[i:]...), name)
+				}
+			}
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range deps[name] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// SetNames returns the command set keys in sorted order
+func (c *Config) SetNames() []string {
+	keys := make([]string, 0, len(c.CommandSets))
+	for key := range c.CommandSets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Entries returns the resolved commands of every set in set order
+func (c *Config) Entries() []CommandEntry {
+	var entries []CommandEntry
+	for _, key := range c.SetNames() {
+		entries = append(entries, c.CommandSets[key].Entries(key)...)
+	}
+	return entries
+}
+
+// WithCommand returns a copy of the config with entry appended to the
+// command set key, which is created if it does not exist yet. Unnamed
+// commands of the set keep the names they had, so adding a command does not
+// rename them.
+func (c *Config) WithCommand(key string, entry CommandEntry) (*Config, error) {
+	if c.imported[key] {
+		return nil, fmt.Errorf("command set %q is imported and cannot be changed", key)
+	}
+
+	next := *c
+	next.CommandSets = make(map[string]CommandSet, len(c.CommandSets)+1)
+	for k, set := range c.CommandSets {
+		next.CommandSets[k] = set
+	}
+
+	set := next.CommandSets[key]
+	commands := make([]CommandEntry, 0, len(set.Commands)+1)
+	for i, resolved := range set.Entries(key) {
+		existing := set.Commands[i]
+		existing.Name = resolved.Name
+		commands = append(commands, existing)
+	}
+	set.Commands = append(commands, entry)
+	next.CommandSets[key] = set
+
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// AddCommand appends entry to the command set key in a config file, which
+// is created if it does not exist yet. Only the set is changed, the rest of
+// the file, including comments, is kept. Unnamed commands of the set get
+// the names they had written out where adding a command would rename them.
+func AddCommand(filename, key string, entry CommandEntry) error {
+	return editFile(filename, func(root *yaml.Node) error {
+		sets := mappingValue(root, "commands")
+		if sets == nil || sets.Kind != yaml.MappingNode {
+			sets = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingValue(root, "commands", sets)
+		}
+		setNode := mappingValue(sets, key)
+		if setNode == nil || setNode.Kind != yaml.MappingNode {
+			setNode = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingValue(sets, key, setNode)
+		}
+
+		var set CommandSet
+		if err := setNode.Decode(&set); err != nil {
+			return fmt.Errorf("failed to parse command set %q: %w", key, err)
+		}
+		list := mappingValue(setNode, "commands")
+		if list == nil || list.Kind != yaml.SequenceNode {
+			list = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			setMappingValue(setNode, "commands", list)
+		}
+
+		before := set.Entries(key)
+		set.Commands = append(set.Commands, entry)
+		for i, after := range set.Entries(key)[:len(before)] {
+			if after.Name != before[i].Name {
+				nameEntry(list.Content[i], before[i].Name)
+			}
+		}
+
+		var node yaml.Node
+		if err := node.Encode(entry); err != nil {
+			return fmt.Errorf("failed to marshal command: %w", err)
+		}
+		list.Content = append(list.Content, &node)
+		return nil
+	})
+}
+
+// AddCommandSets adds command sets to a config file, in the order of their
+// keys. Sets with the same key are replaced. The rest of the file,
+// including comments, is kept.
+func AddCommandSets(filename string, sets map[string]CommandSet) error {
+	keys := make([]string, 0, len(sets))
+	for key := range sets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return editFile(filename, func(root *yaml.Node) error {
+		setsNode := mappingValue(root, "commands")
+		if setsNode == nil || setsNode.Kind != yaml.MappingNode {
+			setsNode = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingValue(root, "commands", setsNode)
+		}
+		for _, key := range keys {
+			var node yaml.Node
+			if err := node.Encode(sets[key]); err != nil {
+				return fmt.Errorf("failed to marshal command set %q: %w", key, err)
+			}
+			setMappingValue(setsNode, key, &node)
+		}
+		return nil
+	})
+}
+
+// nameEntry writes a name into the node of a command entry, turning an
+// entry written as a plain string into a mapping
+func nameEntry(node *yaml.Node, name string) {
+	nameNodes := []*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "name"},
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: name},
+	}
+	if node.Kind != yaml.ScalarNode {
+		node.Content = append(nameNodes, node.Content...)
+		return
+	}
+
+	// The comment above the entry stays above it
+	run := *node
+	run.HeadComment = ""
+	*node = yaml.Node{
+		Kind:        yaml.MappingNode,
+		Tag:         "!!map",
+		HeadComment: node.HeadComment,
+		Content:     append(nameNodes, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "run"}, &run),
+	}
+}
+
 // Save saves configuration to a file
 func (c *Config) Save(filename string) error {
-	data, err := yaml.Marshal(c)
+	return c.SaveWithComments(filename, "", nil)
+}
+
+// SaveWithComments saves configuration to a file like Save, writing header
+// as a comment at the top of the file and comments[key] above the command
+// set with that key
+func (c *Config) SaveWithComments(filename, header string, comments map[string]string) error {
+	// Imported sets stay in their source files
+	out := *c
+	if len(c.imported) > 0 {
+		out.CommandSets = make(map[string]CommandSet)
+		for key, set := range c.CommandSets {
+			if !c.imported[key] {
+				out.CommandSets[key] = set
+			}
+		}
+	}
+
+	var doc yaml.Node
+	if err := doc.Encode(&out); err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	doc.HeadComment = header
+
+	if sets := mappingValue(&doc, "commands"); sets != nil {
+		for i := 0; i < len(sets.Content); i += 2 {
+			sets.Content[i].HeadComment = comments[sets.Content[i].Value]
+		}
+	}
+
+	data, err := yaml.Marshal(&doc)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -74,6 +533,22 @@ func (c *Config) Save(filename string) error {
 	return nil
 }
 
+// mappingValue returns the value node stored under key in a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
 // DefaultConfig returns a default configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -81,7 +556,10 @@ func DefaultConfig() *Config {
 			"example": {
 				Name:        "Example",
 				Description: "Example command set",
-				Commands:    []string{"echo 'Hello World'", "sleep 5"},
+				Commands: []CommandEntry{
+					{Name: "hello", Run: "echo 'Hello World'"},
+					{Name: "sleep", Run: "sleep 5"},
+				},
 				Dir:         ".",
 				AutoRestart: false,
 			},
@@ -92,4 +570,4 @@ func DefaultConfig() *Config {
 			RefreshRate: 100,
 		},
 	}
-} 
\ No newline at end of file
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// commandSets builds a config with one set per command, each depending on
+// the commands listed for it
+func commandSets(deps map[string][]string) *Config {
+	cfg := &Config{CommandSets: make(map[string]CommandSet)}
+	for name, dependsOn := range deps {
+		cfg.CommandSets[name] = CommandSet{
+			Commands: []CommandEntry{{Name: name, Run: "run " + name, DependsOn: dependsOn}},
+		}
+	}
+	return cfg
+}
+
+func TestValidateDependencies(t *testing.T) {
+	tests := []struct {
+		name string
+		deps map[string][]string
+		err  string
+	}{
+		{"none", map[string][]string{"a": nil, "b": nil}, ""},
+		{"chain", map[string][]string{"a": {"b"}, "b": {"c"}, "c": nil}, ""},
+		{"shared dependency", map[string][]string{"a": {"b", "c"}, "b": {"c"}, "c": nil}, ""},
+		{"unknown", map[string][]string{"a": {"x"}}, `command "a" depends on unknown command "x"`},
+		{"self", map[string][]string{"a": {"a"}}, "depends_on forms a cycle: a -> a"},
+		{"two commands", map[string][]string{"a": {"b"}, "b": {"a"}}, "depends_on forms a cycle: a -> b -> a"},
+		{"behind a chain", map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"d"}, "d": {"b"}}, "depends_on forms a cycle: b -> c -> d -> b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := commandSets(tt.deps).Validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("Validate() error = %v, want none", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("Validate() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateScheduledSteps(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  RunMode
+		entry CommandEntry
+		err   string
+	}{
+		{"parallel schedule", ModeParallel, CommandEntry{Run: "./job", Schedule: "@hourly"}, ""},
+		{"race interval", ModeRace, CommandEntry{Run: "./job", Every: time.Minute}, ""},
+		{"sequential schedule", ModeSequential, CommandEntry{Run: "./job", Schedule: "@hourly"}, "cannot be a step of sequential set"},
+		{"sequential interval", ModeSequential, CommandEntry{Run: "./job", Every: time.Minute}, "cannot be a step of sequential set"},
+		{"sequential once", ModeSequential, CommandEntry{Run: "./job"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{CommandSets: map[string]CommandSet{
+				"jobs": {Mode: tt.mode, Commands: []CommandEntry{{Run: "make"}, tt.entry}},
+			}}
+			err := cfg.Validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("Validate() error = %v, want none", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("Validate() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
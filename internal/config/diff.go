@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// EntryChange is a command that was added to or changed in a command set
+type EntryChange struct {
+	Set   string
+	Entry CommandEntry
+}
+
+// Diff describes how the commands of two configs differ. Parallel sets are
+// compared command by command; sets using another run mode are restarted
+// as a whole when anything in them changes.
+type Diff struct {
+	AddedSets     []string
+	RemovedSets   []string
+	RestartedSets []string
+	Added         []EntryChange
+	Changed       []EntryChange
+	Removed       []string
+
+	started, restarted, stopped int
+}
+
+// Compare computes the changes needed to go from old to new
+func Compare(old, new *Config) Diff {
+	var diff Diff
+
+	for _, key := range old.SetNames() {
+		if _, exists := new.CommandSets[key]; !exists {
+			diff.RemovedSets = append(diff.RemovedSets, key)
+			diff.stopped += len(old.CommandSets[key].Commands)
+		}
+	}
+
+	for _, key := range new.SetNames() {
+		newSet := new.CommandSets[key]
+		oldSet, exists := old.CommandSets[key]
+		if !exists {
+			diff.AddedSets = append(diff.AddedSets, key)
+			diff.started += len(newSet.Commands)
+			continue
+		}
+
+		oldEntries := oldSet.Entries(key)
+		newEntries := newSet.Entries(key)
+		if reflect.DeepEqual(oldEntries, newEntries) && sameSetOptions(oldSet, newSet) {
+			continue
+		}
+
+		// Set hooks run once for the whole set, so changing them restarts it
+		if !isParallel(oldSet) || !isParallel(newSet) || !reflect.DeepEqual(oldSet.Hooks, newSet.Hooks) {
+			diff.RestartedSets = append(diff.RestartedSets, key)
+			diff.restarted += len(newEntries)
+			continue
+		}
+
+		oldByName := make(map[string]CommandEntry)
+		for _, entry := range oldEntries {
+			oldByName[entry.Name] = entry
+		}
+		newByName := make(map[string]bool)
+
+		for _, entry := range newEntries {
+			newByName[entry.Name] = true
+			previous, existed := oldByName[entry.Name]
+			switch {
+			case !existed:
+				diff.Added = append(diff.Added, EntryChange{Set: key, Entry: entry})
+				diff.started++
+			case !reflect.DeepEqual(previous, entry):
+				diff.Changed = append(diff.Changed, EntryChange{Set: key, Entry: entry})
+				diff.restarted++
+			}
+		}
+
+		for _, entry := range oldEntries {
+			if !newByName[entry.Name] {
+				diff.Removed = append(diff.Removed, entry.Name)
+				diff.stopped++
+			}
+		}
+	}
+
+	return diff
+}
+
+// Empty reports whether the diff contains no changes
+func (d Diff) Empty() bool {
+	return d.started == 0 && d.restarted == 0 && d.stopped == 0
+}
+
+// Summary describes the diff in a short human readable line
+func (d Diff) Summary() string {
+	if d.Empty() {
+		return "no changes"
+	}
+
+	var parts []string
+	if d.started > 0 {
+		parts = append(parts, fmt.Sprintf("%d started", d.started))
+	}
+	if d.restarted > 0 {
+		parts = append(parts, fmt.Sprintf("%d restarted", d.restarted))
+	}
+	if d.stopped > 0 {
+		parts = append(parts, fmt.Sprintf("%d stopped", d.stopped))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sameSetOptions compares the set-level options that affect how a set runs
+func sameSetOptions(a, b CommandSet) bool {
+	return a.Name == b.Name && a.Mode == b.Mode && a.ContinueOnError == b.ContinueOnError &&
+		reflect.DeepEqual(a.Hooks, b.Hooks)
+}
+
+// isParallel reports whether a set runs in parallel mode
+func isParallel(set CommandSet) bool {
+	return set.Mode == "" || set.Mode == ModeParallel
+}
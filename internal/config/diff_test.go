@@ -0,0 +1,129 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+// changes is a Diff with its entries reduced to "set/name: run"
+type changes struct {
+	addedSets, removedSets, restartedSets []string
+	added, changed, removed               []string
+}
+
+// changesOf reduces a diff for comparison
+func changesOf(diff Diff) changes {
+	entries := func(list []EntryChange) []string {
+		var result []string
+		for _, change := range list {
+			result = append(result, change.Set+"/"+change.Entry.Name+": "+change.Entry.Run)
+		}
+		return result
+	}
+	return changes{
+		addedSets:     diff.AddedSets,
+		removedSets:   diff.RemovedSets,
+		restartedSets: diff.RestartedSets,
+		added:         entries(diff.Added),
+		changed:       entries(diff.Changed),
+		removed:       diff.Removed,
+	}
+}
+
+func TestCompare(t *testing.T) {
+	web := CommandSet{Commands: []CommandEntry{{Name: "api", Run: "./api"}, {Name: "ui", Run: "npm run dev"}}}
+	build := CommandSet{Mode: ModeSequential, Commands: []CommandEntry{{Run: "make"}, {Run: "make test"}}}
+
+	// with returns a copy of web with its commands changed by change
+	with := func(change func(commands []CommandEntry) []CommandEntry) CommandSet {
+		set := web
+		set.Commands = change(append([]CommandEntry(nil), web.Commands...))
+		return set
+	}
+
+	tests := []struct {
+		name    string
+		old     map[string]CommandSet
+		new     map[string]CommandSet
+		want    changes
+		summary string
+	}{
+		{
+			name:    "unchanged",
+			old:     map[string]CommandSet{"web": web, "build": build},
+			new:     map[string]CommandSet{"web": web, "build": build},
+			summary: "no changes",
+		},
+		{
+			name:    "set added",
+			old:     map[string]CommandSet{"web": web},
+			new:     map[string]CommandSet{"web": web, "build": build},
+			want:    changes{addedSets: []string{"build"}},
+			summary: "2 started",
+		},
+		{
+			name:    "set removed",
+			old:     map[string]CommandSet{"web": web, "build": build},
+			new:     map[string]CommandSet{"web": web},
+			want:    changes{removedSets: []string{"build"}},
+			summary: "2 stopped",
+		},
+		{
+			name: "command changed",
+			old:  map[string]CommandSet{"web": web},
+			new: map[string]CommandSet{"web": with(func(c []CommandEntry) []CommandEntry {
+				c[0].Run = "./api -debug"
+				return c
+			})},
+			want:    changes{changed: []string{"web/api: ./api -debug"}},
+			summary: "1 restarted",
+		},
+		{
+			name: "command added and removed",
+			old:  map[string]CommandSet{"web": web},
+			new: map[string]CommandSet{"web": with(func(c []CommandEntry) []CommandEntry {
+				return append(c[1:], CommandEntry{Name: "docs", Run: "hugo serve"})
+			})},
+			want:    changes{added: []string{"web/docs: hugo serve"}, removed: []string{"api"}},
+			summary: "1 started, 1 stopped",
+		},
+		{
+			name: "sequential set changed",
+			old:  map[string]CommandSet{"build": build},
+			new: map[string]CommandSet{"build": {Mode: ModeSequential, Commands: []CommandEntry{
+				{Run: "make"}, {Run: "make check"},
+			}}},
+			want:    changes{restartedSets: []string{"build"}},
+			summary: "2 restarted",
+		},
+		{
+			name:    "mode changed",
+			old:     map[string]CommandSet{"web": web},
+			new:     map[string]CommandSet{"web": {Mode: ModeRace, Commands: web.Commands}},
+			want:    changes{restartedSets: []string{"web"}},
+			summary: "2 restarted",
+		},
+		{
+			name:    "set hooks changed",
+			old:     map[string]CommandSet{"web": web},
+			new:     map[string]CommandSet{"web": {Commands: web.Commands, Hooks: &Hooks{PreStart: HookList{"make generate"}}}},
+			want:    changes{restartedSets: []string{"web"}},
+			summary: "2 restarted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := Compare(&Config{CommandSets: tt.old}, &Config{CommandSets: tt.new})
+			if got := diff.Summary(); got != tt.summary {
+				t.Errorf("Summary() = %q, want %q", got, tt.summary)
+			}
+			if got := diff.Empty(); got != (tt.summary == "no changes") {
+				t.Errorf("Empty() = %v, want %v", got, !got)
+			}
+			if got := changesOf(diff); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compare() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
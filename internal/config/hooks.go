@@ -0,0 +1,66 @@
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// Hook stages, named after their YAML keys
+const (
+	HookPreStart  = "pre_start"
+	HookPostStart = "post_start"
+	HookPreStop   = "pre_stop"
+	HookPostStop  = "post_stop"
+	HookOnFailure = "on_failure"
+	HookOnSuccess = "on_success"
+)
+
+// Hooks are commands run at points of a command's lifecycle. Hooks of a
+// command set run once around the lifecycle of the whole set instead.
+// Each stage takes a single command line or a list of them.
+type Hooks struct {
+	PreStart  HookList `yaml:"pre_start,omitempty"`
+	PostStart HookList `yaml:"post_start,omitempty"`
+	PreStop   HookList `yaml:"pre_stop,omitempty"`
+	PostStop  HookList `yaml:"post_stop,omitempty"`
+	OnFailure HookList `yaml:"on_failure,omitempty"`
+	OnSuccess HookList `yaml:"on_success,omitempty"`
+	// Blocking makes a failing pre_start or post_start hook fail the
+	// command, or every command of the set, instead of only being reported
+	Blocking bool `yaml:"blocking,omitempty"`
+}
+
+// Stage returns the hooks of a stage
+func (h *Hooks) Stage(stage string) []string {
+	if h == nil {
+		return nil
+	}
+
+	switch stage {
+	case HookPreStart:
+		return h.PreStart
+	case HookPostStart:
+		return h.PostStart
+	case HookPreStop:
+		return h.PreStop
+	case HookPostStop:
+		return h.PostStop
+	case HookOnFailure:
+		return h.OnFailure
+	case HookOnSuccess:
+		return h.OnSuccess
+	}
+	return nil
+}
+
+// HookList is a list of command lines that may be written as one string
+type HookList []string
+
+// UnmarshalYAML accepts a scalar or a sequence
+func (l *HookList) UnmarshalYAML(value *yaml.Node) error {
+	var list stringList
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = HookList(list)
+	return nil
+}
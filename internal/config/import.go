@@ -0,0 +1,448 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Source types understood by Import
+const (
+	SourceProcfile = "procfile"
+	SourceCompose  = "compose"
+	SourceNPM      = "npm"
+	SourceMake     = "make"
+)
+
+// Source references a file in another format that describes processes.
+// Sources listed in a config file are imported every time it is loaded.
+type Source struct {
+	Type   string   `yaml:"type,omitempty"`
+	Path   string   `yaml:"path"`
+	Only   []string `yaml:"only,omitempty"`
+	Prefix string   `yaml:"prefix,omitempty"`
+}
+
+// DetectSourceType guesses the source type from a file name
+func DetectSourceType(path string) (string, error) {
+	base := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.HasPrefix(base, "procfile"):
+		return SourceProcfile, nil
+	case strings.Contains(base, "compose") && (strings.HasSuffix(base, ".yml") || strings.HasSuffix(base, ".yaml")):
+		return SourceCompose, nil
+	case base == "package.json":
+		return SourceNPM, nil
+	case base == "makefile" || base == "gnumakefile" || strings.HasSuffix(base, ".mk"):
+		return SourceMake, nil
+	}
+	return "", fmt.Errorf("cannot detect the type of %s", path)
+}
+
+// Import converts a source into command sets keyed by set name
+func Import(src Source) (map[string]CommandSet, error) {
+	kind := src.Type
+	if kind == "" {
+		var err error
+		if kind, err = DetectSourceType(src.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	var sets map[string]CommandSet
+	var err error
+	switch kind {
+	case SourceProcfile:
+		sets, err = importProcfile(src.Path)
+	case SourceCompose:
+		sets, err = importCompose(src.Path)
+	case SourceNPM:
+		sets, err = importPackageJSON(src.Path)
+	case SourceMake:
+		sets, err = importMakefile(src.Path, src.Only)
+	default:
+		return nil, fmt.Errorf("unknown source type %q", kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to import %s: %w", src.Path, err)
+	}
+
+	if len(src.Only) > 0 {
+		for key := range sets {
+			if !contains(src.Only, key) {
+				delete(sets, key)
+			}
+		}
+	}
+
+	if src.Prefix != "" {
+		sets = prefixSets(sets, src.Prefix)
+	}
+
+	return sets, nil
+}
+
+// loadSources imports the sources of a config that was read from filename.
+// Sets defined explicitly in the config take precedence over imported ones.
+func (c *Config) loadSources(filename string) error {
+	base := filepath.Dir(filename)
+	for _, src := range c.Sources {
+		if !filepath.IsAbs(src.Path) {
+			src.Path = filepath.Join(base, src.Path)
+		}
+
+		sets, err := Import(src)
+		if err != nil {
+			return err
+		}
+		c.files = append(c.files, src.Path)
+
+		for key, set := range sets {
+			if _, exists := c.CommandSets[key]; exists {
+				if c.imported[key] {
+					return fmt.Errorf("command set %q from %s is already imported from another source", key, src.Path)
+				}
+				continue
+			}
+			if c.CommandSets == nil {
+				c.CommandSets = make(map[string]CommandSet)
+			}
+			if c.imported == nil {
+				c.imported = make(map[string]bool)
+			}
+			c.CommandSets[key] = set
+			c.imported[key] = true
+		}
+	}
+	return nil
+}
+
+// importProcfile reads "name: command" lines from a Procfile
+func importProcfile(path string) (map[string]CommandSet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sets := make(map[string]CommandSet)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, command, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid Procfile line %q", line)
+		}
+		name = strings.TrimSpace(name)
+		sets[name] = singleCommandSet(name, "Procfile process", strings.TrimSpace(command), filepath.Dir(path))
+	}
+
+	return sets, scanner.Err()
+}
+
+// composeFile is the part of a docker-compose file cmdpool understands
+type composeFile struct {
+	Services map[string]struct {
+		Command     stringList   `yaml:"command"`
+		Build       composeBuild `yaml:"build"`
+		Environment envList      `yaml:"environment"`
+		DependsOn   keyList      `yaml:"depends_on"`
+	} `yaml:"services"`
+}
+
+// importCompose converts the services of a docker-compose file
+func importCompose(path string) (map[string]CommandSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var compose composeFile
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(path)
+	sets := make(map[string]CommandSet)
+	for name, service := range compose.Services {
+		// Services without a command of their own are run through compose.
+		// Commands run on the host in the build context; working_dir is a
+		// path inside the container and does not apply.
+		run := joinCommand(service.Command)
+		serviceDir := dir
+		if run == "" {
+			run = fmt.Sprintf("docker compose -f %s up %s", filepath.Base(path), name)
+		} else if context := service.Build.Context; context != "" && !strings.Contains(context, "://") {
+			serviceDir = context
+			if !filepath.IsAbs(serviceDir) {
+				serviceDir = filepath.Join(dir, serviceDir)
+			}
+		}
+
+		set := singleCommandSet(name, "docker-compose service", run, serviceDir)
+		set.Commands[0].Env = service.Environment
+		set.Commands[0].DependsOn = service.DependsOn
+		sets[name] = set
+	}
+
+	return sets, nil
+}
+
+// importPackageJSON converts the scripts of a package.json into npm commands
+func importPackageJSON(path string) (map[string]CommandSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, err
+	}
+
+	sets := make(map[string]CommandSet)
+	for name := range pkg.Scripts {
+		// npm runs pre/post scripts around their main script by itself
+		if isLifecycleScript(name, pkg.Scripts) {
+			continue
+		}
+		sets[name] = singleCommandSet(name, "npm script", "npm run "+name, filepath.Dir(path))
+	}
+
+	return sets, nil
+}
+
+// makeTarget matches explicit rule targets at the start of a line, written
+// with ":" or "::" but not the assignments ":=", "::=" and ":::="
+var makeTarget = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_./-]*)\s*::?([^:=]|$)`)
+
+// importMakefile converts Makefile targets into make commands. Without a
+// selection every explicit target is imported.
+func importMakefile(path string, only []string) (map[string]CommandSet, error) {
+	targets, err := MakefileTargets(path)
+	if err != nil {
+		return nil, err
+	}
+
+	run := "make "
+	if base := filepath.Base(path); base != "Makefile" && base != "makefile" && base != "GNUmakefile" {
+		run = fmt.Sprintf("make -f %s ", base)
+	}
+
+	sets := make(map[string]CommandSet)
+	for _, target := range targets {
+		if len(only) > 0 && !contains(only, target) {
+			continue
+		}
+		sets[target] = singleCommandSet(target, "make target", run+target, filepath.Dir(path))
+	}
+
+	for _, target := range only {
+		if _, exists := sets[target]; !exists {
+			return nil, fmt.Errorf("target %q not found", target)
+		}
+	}
+
+	return sets, nil
+}
+
+// MakefileTargets lists the explicit targets of a Makefile in file order
+func MakefileTargets(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var targets []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		match := makeTarget.FindStringSubmatch(scanner.Text())
+		if match == nil || seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		targets = append(targets, match[1])
+	}
+
+	return targets, scanner.Err()
+}
+
+// singleCommandSet builds a set holding one named command
+func singleCommandSet(name, description, run, dir string) CommandSet {
+	return CommandSet{
+		Name:        name,
+		Description: description,
+		Commands:    []CommandEntry{{Name: name, Run: run}},
+		Dir:         dir,
+	}
+}
+
+// prefixSets prepends prefix to set keys and command names, including
+// the names referenced by depends_on
+func prefixSets(sets map[string]CommandSet, prefix string) map[string]CommandSet {
+	result := make(map[string]CommandSet, len(sets))
+	for key, set := range sets {
+		set.Name = prefix + set.Name
+		commands := make([]CommandEntry, len(set.Commands))
+		for i, entry := range set.Commands {
+			if entry.Name != "" {
+				entry.Name = prefix + entry.Name
+			}
+			deps := make([]string, len(entry.DependsOn))
+			for j, dep := range entry.DependsOn {
+				deps[j] = prefix + dep
+			}
+			if len(deps) > 0 {
+				entry.DependsOn = deps
+			}
+			commands[i] = entry
+		}
+		set.Commands = commands
+		result[prefix+key] = set
+	}
+	return result
+}
+
+// isLifecycleScript reports whether an npm script is a pre/post hook of
+// another script
+func isLifecycleScript(name string, scripts map[string]string) bool {
+	for _, prefix := range []string{"pre", "post"} {
+		if main, found := strings.CutPrefix(name, prefix); found {
+			if _, exists := scripts[main]; exists {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// joinCommand turns an argument list into a command line, quoting
+// arguments that contain spaces
+func joinCommand(args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t") {
+			arg = `"` + arg + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// contains reports whether list holds value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList decodes either a single string or a list of strings
+type stringList []string
+
+// UnmarshalYAML accepts a scalar or a sequence
+func (l *stringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = stringList{value.Value}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// composeBuild is the build section of a compose service, written either
+// as the context path or as a mapping
+type composeBuild struct {
+	Context string `yaml:"context"`
+}
+
+// UnmarshalYAML accepts a scalar or a mapping
+func (b *composeBuild) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		b.Context = value.Value
+		return nil
+	}
+	var build struct {
+		Context string `yaml:"context"`
+	}
+	if err := value.Decode(&build); err != nil {
+		return err
+	}
+	b.Context = build.Context
+	return nil
+}
+
+// envList decodes environment variables written either as a mapping or
+// as a list of KEY=value strings
+type envList []string
+
+// UnmarshalYAML accepts a mapping or a sequence
+func (l *envList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		*l = list
+		return nil
+	}
+
+	var env map[string]string
+	if err := value.Decode(&env); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		*l = append(*l, key+"="+env[key])
+	}
+	return nil
+}
+
+// keyList decodes either a list of names or the keys of a mapping
+type keyList []string
+
+// UnmarshalYAML accepts a mapping or a sequence
+func (l *keyList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		*l = list
+		return nil
+	}
+
+	for i := 0; i < len(value.Content); i += 2 {
+		*l = append(*l, value.Content[i].Value)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFile creates a file named name with content in dir and returns its path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDetectSourceType(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"Procfile", SourceProcfile},
+		{"Procfile.dev", SourceProcfile},
+		{"docker-compose.yml", SourceCompose},
+		{"compose.override.yaml", SourceCompose},
+		{"web/package.json", SourceNPM},
+		{"Makefile", SourceMake},
+		{"GNUmakefile", SourceMake},
+		{"build/rules.mk", SourceMake},
+		{"compose.json", ""},
+		{"README.md", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := DetectSourceType(tt.path)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("DetectSourceType(%q) = %q, want an error", tt.path, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("DetectSourceType(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestMakefileTargets(t *testing.T) {
+	tests := []struct {
+		name     string
+		makefile string
+		want     []string
+	}{
+		{"rules", "build: deps\n\tgo build\ntest:\n\tgo test\n", []string{"build", "test"}},
+		{"space before colon", "build : deps\n", []string{"build"}},
+		{"double colon rule", "clean::\n\trm -rf out\n", []string{"clean"}},
+		{"simple assignment", "VERSION := 1.0\n", nil},
+		{"posix assignment", "VERSION ::= 1.0\n", nil},
+		{"immediate escaped assignment", "VERSION :::= 1.0\n", nil},
+		{"other assignments", "A = 1\nB ?= 2\nC += 3\nD != date\n", nil},
+		{"special targets", ".PHONY: build\n.DEFAULT_GOAL := build\n", nil},
+		{"recipe lines", "build:\n\techo a: b\n", []string{"build"}},
+		{"first occurrence only", "build: a\nbuild: b\ntest:\n", []string{"build", "test"}},
+		{"paths", "bin/app: main.go\n", []string{"bin/app"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, t.TempDir(), "Makefile", tt.makefile)
+			got, err := MakefileTargets(path)
+			if err != nil {
+				t.Fatalf("MakefileTargets() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("targets = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		src     Source
+		want    map[string]CommandEntry
+	}{
+		{
+			name:    "procfile",
+			file:    "Procfile",
+			content: "# processes\nweb: bundle exec rails s\n\nworker:  sidekiq -c 2\n",
+			want: map[string]CommandEntry{
+				"web":    {Name: "web", Run: "bundle exec rails s", Dir: "."},
+				"worker": {Name: "worker", Run: "sidekiq -c 2", Dir: "."},
+			},
+		},
+		{
+			name: "compose",
+			file: "docker-compose.yml",
+			content: `services:
+  db:
+    image: postgres
+    environment:
+      POSTGRES_USER: app
+      POSTGRES_DB: app
+  api:
+    build: ./api
+    working_dir: /srv/api
+    command: ["go", "run", "./cmd/api", "-addr", ":8080 :9090"]
+    depends_on:
+      db:
+        condition: service_healthy
+  worker:
+    build:
+      context: worker
+    command: ./worker
+    environment: [QUEUE=jobs]
+    depends_on: [db]
+  admin:
+    working_dir: /srv/admin
+    command: ./admin
+`,
+			want: map[string]CommandEntry{
+				"db":     {Name: "db", Run: "docker compose -f docker-compose.yml up db", Dir: ".", Env: []string{"POSTGRES_DB=app", "POSTGRES_USER=app"}},
+				"api":    {Name: "api", Run: `go run ./cmd/api -addr ":8080 :9090"`, Dir: "api", DependsOn: []string{"db"}},
+				"worker": {Name: "worker", Run: "./worker", Dir: "worker", Env: []string{"QUEUE=jobs"}, DependsOn: []string{"db"}},
+				"admin":  {Name: "admin", Run: "./admin", Dir: "."},
+			},
+		},
+		{
+			name:    "package.json",
+			file:    "package.json",
+			content: `{"scripts": {"dev": "vite", "prebuild": "rm -rf dist", "build": "vite build", "preview": "vite preview"}}`,
+			want: map[string]CommandEntry{
+				"dev":     {Name: "dev", Run: "npm run dev", Dir: "."},
+				"build":   {Name: "build", Run: "npm run build", Dir: "."},
+				"preview": {Name: "preview", Run: "npm run preview", Dir: "."},
+			},
+		},
+		{
+			name:    "makefile",
+			file:    "Makefile",
+			content: "GO ::= go\nbuild:\n\t$(GO) build\ntest: build\n\t$(GO) test\n",
+			want: map[string]CommandEntry{
+				"build": {Name: "build", Run: "make build", Dir: "."},
+				"test":  {Name: "test", Run: "make test", Dir: "."},
+			},
+		},
+		{
+			name:    "other makefile",
+			file:    "tools.mk",
+			content: "lint:\n\tgolangci-lint run\n",
+			want: map[string]CommandEntry{
+				"lint": {Name: "lint", Run: "make -f tools.mk lint", Dir: "."},
+			},
+		},
+		{
+			name:    "only and prefix",
+			file:    "docker-compose.yml",
+			content: "services:\n  api:\n    command: ./api\n    depends_on: [db]\n  db:\n    image: postgres\n  cache:\n    image: redis\n",
+			src:     Source{Only: []string{"api", "db"}, Prefix: "dc:"},
+			want: map[string]CommandEntry{
+				"dc:api": {Name: "dc:api", Run: "./api", Dir: ".", DependsOn: []string{"dc:db"}},
+				"dc:db":  {Name: "dc:db", Run: "docker compose -f docker-compose.yml up db", Dir: "."},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := tt.src
+			src.Path = writeFile(t, dir, tt.file, tt.content)
+
+			sets, err := Import(src)
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+
+			got := make(map[string]CommandEntry)
+			for key, set := range sets {
+				if len(set.Commands) != 1 {
+					t.Fatalf("set %q has %d commands, want 1", key, len(set.Commands))
+				}
+				entry := set.Commands[0]
+				entry.Dir = set.Dir
+				if rel, err := filepath.Rel(dir, entry.Dir); err == nil {
+					entry.Dir = rel
+				}
+				got[key] = entry
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Import() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		src     Source
+	}{
+		{"invalid procfile line", "Procfile", "web ./web\n", Source{}},
+		{"invalid compose file", "compose.yml", "services: [", Source{}},
+		{"invalid package.json", "package.json", "{", Source{}},
+		{"missing make target", "Makefile", "build:\n", Source{Only: []string{"deploy"}}},
+		{"unknown type", "Procfile", "web: ./web\n", Source{Type: "foreman"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := tt.src
+			src.Path = writeFile(t, t.TempDir(), tt.file, tt.content)
+			if _, err := Import(src); err == nil {
+				t.Errorf("Import() succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,492 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyContext is the part of the TUI a key binding applies to
+type KeyContext string
+
+const (
+	// KeyContextList applies while the command panels are shown
+	KeyContextList KeyContext = "list"
+	// KeyContextZoom applies while a panel is shown full screen
+	KeyContextZoom KeyContext = "zoom"
+	// KeyContextTimeline applies while the merged output of the commands
+	// is shown
+	KeyContextTimeline KeyContext = "timeline"
+	// KeyContextSearch applies while typing a search pattern
+	KeyContextSearch KeyContext = "search"
+	// KeyContextInput applies while typing into a dialog
+	KeyContextInput KeyContext = "input"
+)
+
+// KeyContexts lists the key contexts
+var KeyContexts = []KeyContext{KeyContextList, KeyContextZoom, KeyContextTimeline, KeyContextSearch, KeyContextInput}
+
+// Key presets, the bindings the keys config starts from
+const (
+	KeyPresetDefault = "default"
+	KeyPresetVim     = "vim"
+)
+
+// Actions that keys can be bound to
+const (
+	ActionNext       = "next"
+	ActionPrev       = "prev"
+	ActionZoom       = "zoom"
+	ActionClose      = "close"
+	ActionFollow     = "follow"
+	ActionScrollUp   = "scroll_up"
+	ActionScrollDown = "scroll_down"
+	ActionPageUp     = "page_up"
+	ActionPageDown   = "page_down"
+	ActionTop        = "top"
+	ActionBottom     = "bottom"
+	ActionSearch     = "search"
+	ActionSearchAll  = "search_all"
+	ActionNextMatch  = "next_match"
+	ActionPrevMatch  = "prev_match"
+	ActionFilter     = "filter"
+	ActionStreams    = "streams"
+	ActionLevels     = "levels"
+	ActionTimestamps = "timestamps"
+	ActionLayout     = "layout"
+	ActionTimeline   = "timeline"
+	ActionFreeze     = "freeze"
+	ActionSelect     = "select"
+	ActionRestart    = "restart"
+	ActionStop       = "stop"
+	ActionSave       = "save"
+	ActionCopy       = "copy"
+	ActionExport     = "export"
+	ActionAdd        = "add"
+	ActionHelp       = "help"
+	ActionPalette    = "palette"
+	ActionQuit       = "quit"
+	ActionSubmit     = "submit"
+	ActionCancel     = "cancel"
+)
+
+// KeyActions lists the actions of each context in the order they are
+// shown in the help
+var KeyActions = map[KeyContext][]string{
+	KeyContextList: {
+		ActionPrev, ActionNext, ActionZoom, ActionSearch, ActionSearchAll,
+		ActionNextMatch, ActionPrevMatch, ActionFilter, ActionStreams,
+		ActionLevels, ActionTimestamps, ActionLayout, ActionTimeline,
+		ActionRestart, ActionStop, ActionSave, ActionCopy, ActionExport,
+		ActionAdd, ActionPalette, ActionHelp, ActionQuit,
+	},
+	KeyContextZoom: {
+		ActionClose, ActionFollow, ActionScrollUp, ActionScrollDown,
+		ActionPageUp, ActionPageDown, ActionTop, ActionBottom, ActionSearch,
+		ActionSearchAll, ActionNextMatch, ActionPrevMatch, ActionFilter,
+		ActionStreams, ActionLevels, ActionTimestamps, ActionTimeline,
+		ActionRestart, ActionStop, ActionSave, ActionCopy, ActionExport,
+		ActionPalette, ActionHelp, ActionQuit,
+	},
+	KeyContextTimeline: {
+		ActionClose, ActionFreeze, ActionFollow, ActionScrollUp,
+		ActionScrollDown, ActionPageUp, ActionPageDown, ActionTop,
+		ActionBottom, ActionSearch, ActionNextMatch, ActionPrevMatch,
+		ActionFilter, ActionStreams, ActionLevels, ActionTimestamps,
+		ActionSelect, ActionCopy, ActionExport, ActionPalette, ActionHelp,
+		ActionQuit,
+	},
+	KeyContextSearch: {
+		ActionSubmit, ActionCancel, ActionNextMatch, ActionPrevMatch,
+	},
+	KeyContextInput: {
+		ActionCancel,
+	},
+}
+
+// KeyPresets holds the bindings of each preset
+var KeyPresets = map[string]map[KeyContext]KeyBindings{
+	KeyPresetDefault: {
+		KeyContextList: {
+			ActionPrev:       {"up", "left"},
+			ActionNext:       {"down", "right"},
+			ActionZoom:       {"enter"},
+			ActionSearch:     {"/"},
+			ActionSearchAll:  {"ctrl+f"},
+			ActionNextMatch:  {"n"},
+			ActionPrevMatch:  {"N"},
+			ActionFilter:     {"f"},
+			ActionStreams:    {"e"},
+			ActionLevels:     {"v"},
+			ActionTimestamps: {"t"},
+			ActionLayout:     {"L"},
+			ActionTimeline:   {"T"},
+			ActionRestart:    {"r"},
+			ActionStop:       {"s"},
+			ActionSave:       {"w"},
+			ActionCopy:       {"y"},
+			ActionExport:     {"W"},
+			ActionAdd:        {"+"},
+			ActionHelp:       {"?"},
+			ActionPalette:    {"ctrl+p"},
+			ActionQuit:       {"q"},
+		},
+		KeyContextZoom: {
+			ActionClose:      {"esc", "enter"},
+			ActionFollow:     {"F"},
+			ActionScrollUp:   {"up"},
+			ActionScrollDown: {"down"},
+			ActionPageUp:     {"pgup"},
+			ActionPageDown:   {"pgdn"},
+			ActionTop:        {"home"},
+			ActionBottom:     {"end"},
+			ActionSearch:     {"/"},
+			ActionSearchAll:  {"ctrl+f"},
+			ActionNextMatch:  {"n"},
+			ActionPrevMatch:  {"N"},
+			ActionFilter:     {"f"},
+			ActionStreams:    {"e"},
+			ActionLevels:     {"v"},
+			ActionTimestamps: {"t"},
+			ActionTimeline:   {"T"},
+			ActionRestart:    {"r"},
+			ActionStop:       {"s"},
+			ActionSave:       {"w"},
+			ActionCopy:       {"y"},
+			ActionExport:     {"W"},
+			ActionHelp:       {"?"},
+			ActionPalette:    {"ctrl+p"},
+			ActionQuit:       {"q"},
+		},
+		KeyContextTimeline: {
+			ActionClose:      {"esc", "T"},
+			ActionFreeze:     {"space"},
+			ActionFollow:     {"F"},
+			ActionScrollUp:   {"up"},
+			ActionScrollDown: {"down"},
+			ActionPageUp:     {"pgup"},
+			ActionPageDown:   {"pgdn"},
+			ActionTop:        {"home"},
+			ActionBottom:     {"end"},
+			ActionSearch:     {"/"},
+			ActionNextMatch:  {"n"},
+			ActionPrevMatch:  {"N"},
+			ActionFilter:     {"f"},
+			ActionStreams:    {"e"},
+			ActionLevels:     {"v"},
+			ActionTimestamps: {"t"},
+			ActionSelect:     {"c"},
+			ActionCopy:       {"y"},
+			ActionExport:     {"W"},
+			ActionHelp:       {"?"},
+			ActionPalette:    {"ctrl+p"},
+			ActionQuit:       {"q"},
+		},
+		KeyContextSearch: {
+			ActionSubmit:    {"enter"},
+			ActionCancel:    {"esc"},
+			ActionNextMatch: {"down"},
+			ActionPrevMatch: {"up"},
+		},
+		KeyContextInput: {
+			ActionCancel: {"esc"},
+		},
+	},
+	KeyPresetVim: {
+		KeyContextList: {
+			ActionPrev:       {"k", "h", "up", "left"},
+			ActionNext:       {"j", "l", "down", "right"},
+			ActionZoom:       {"enter", "o"},
+			ActionSearch:     {"/"},
+			ActionSearchAll:  {"ctrl+f"},
+			ActionNextMatch:  {"n"},
+			ActionPrevMatch:  {"N"},
+			ActionFilter:     {"f"},
+			ActionStreams:    {"e"},
+			ActionLevels:     {"v"},
+			ActionTimestamps: {"t"},
+			ActionLayout:     {"L"},
+			ActionTimeline:   {"T"},
+			ActionRestart:    {"r"},
+			ActionStop:       {"s"},
+			ActionSave:       {"w"},
+			ActionCopy:       {"y"},
+			ActionExport:     {"W"},
+			ActionAdd:        {"a", "+"},
+			ActionHelp:       {"?"},
+			ActionPalette:    {"ctrl+p"},
+			ActionQuit:       {"q"},
+		},
+		KeyContextZoom: {
+			ActionClose:      {"q", "esc", "enter"},
+			ActionFollow:     {"F"},
+			ActionScrollUp:   {"k", "up"},
+			ActionScrollDown: {"j", "down"},
+			ActionPageUp:     {"ctrl+u", "ctrl+b", "pgup"},
+			ActionPageDown:   {"ctrl+d", "pgdn"},
+			ActionTop:        {"g", "home"},
+			ActionBottom:     {"G", "end"},
+			ActionSearch:     {"/"},
+			ActionSearchAll:  {"ctrl+f"},
+			ActionNextMatch:  {"n"},
+			ActionPrevMatch:  {"N"},
+			ActionFilter:     {"f"},
+			ActionStreams:    {"e"},
+			ActionLevels:     {"v"},
+			ActionTimestamps: {"t"},
+			ActionTimeline:   {"T"},
+			ActionRestart:    {"r"},
+			ActionStop:       {"s"},
+			ActionSave:       {"w"},
+			ActionCopy:       {"y"},
+			ActionExport:     {"W"},
+			ActionHelp:       {"?"},
+			ActionPalette:    {"ctrl+p"},
+			ActionQuit:       {"Q"},
+		},
+		KeyContextTimeline: {
+			ActionClose:      {"q", "esc", "T"},
+			ActionFreeze:     {"space"},
+			ActionFollow:     {"F"},
+			ActionScrollUp:   {"k", "up"},
+			ActionScrollDown: {"j", "down"},
+			ActionPageUp:     {"ctrl+u", "ctrl+b", "pgup"},
+			ActionPageDown:   {"ctrl+d", "pgdn"},
+			ActionTop:        {"g", "home"},
+			ActionBottom:     {"G", "end"},
+			ActionSearch:     {"/"},
+			ActionNextMatch:  {"n"},
+			ActionPrevMatch:  {"N"},
+			ActionFilter:     {"f"},
+			ActionStreams:    {"e"},
+			ActionLevels:     {"v"},
+			ActionTimestamps: {"t"},
+			ActionSelect:     {"c"},
+			ActionCopy:       {"y"},
+			ActionExport:     {"W"},
+			ActionHelp:       {"?"},
+			ActionPalette:    {"ctrl+p"},
+			ActionQuit:       {"Q"},
+		},
+		KeyContextSearch: {
+			ActionSubmit:    {"enter"},
+			ActionCancel:    {"esc", "ctrl+c"},
+			ActionNextMatch: {"ctrl+n", "down"},
+			ActionPrevMatch: {"ctrl+p", "up"},
+		},
+		KeyContextInput: {
+			ActionCancel: {"esc", "ctrl+c"},
+		},
+	},
+}
+
+// KeysConfig configures the key bindings of the TUI. Actions bound in a
+// context replace the keys of the preset for that action, and keys they
+// use are taken away from other actions of the preset.
+type KeysConfig struct {
+	Preset   string      `yaml:"preset,omitempty"`
+	List     KeyBindings `yaml:"list,omitempty"`
+	Zoom     KeyBindings `yaml:"zoom,omitempty"`
+	Timeline KeyBindings `yaml:"timeline,omitempty"`
+	Search   KeyBindings `yaml:"search,omitempty"`
+	Input    KeyBindings `yaml:"input,omitempty"`
+}
+
+// KeyBindings maps actions to the keys that trigger them
+type KeyBindings map[string]KeyList
+
+// KeyList is a list of keys that may be written as one string. An empty
+// list unbinds an action.
+type KeyList []string
+
+// UnmarshalYAML accepts a scalar or a sequence
+func (l *KeyList) UnmarshalYAML(value *yaml.Node) error {
+	var list stringList
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = KeyList(list)
+	return nil
+}
+
+// KeyBinding is an action and the keys bound to it
+type KeyBinding struct {
+	Action string
+	Keys   []string
+}
+
+// context returns the bindings configured for a context
+func (k *KeysConfig) context(context KeyContext) KeyBindings {
+	if k == nil {
+		return nil
+	}
+
+	switch context {
+	case KeyContextList:
+		return k.List
+	case KeyContextZoom:
+		return k.Zoom
+	case KeyContextTimeline:
+		return k.Timeline
+	case KeyContextSearch:
+		return k.Search
+	case KeyContextInput:
+		return k.Input
+	}
+	return nil
+}
+
+// validate checks the preset, actions and key names
+func (k *KeysConfig) validate() error {
+	if k.Preset != "" {
+		if _, exists := KeyPresets[k.Preset]; !exists {
+			return fmt.Errorf("unknown key preset %q", k.Preset)
+		}
+	}
+
+	for _, context := range KeyContexts {
+		for action, keys := range k.context(context) {
+			if !contains(KeyActions[context], action) {
+				return fmt.Errorf("unknown action %q in keys.%s", action, context)
+			}
+			for _, key := range keys {
+				if _, err := NormalizeKey(key); err != nil {
+					return fmt.Errorf("keys.%s.%s: %w", context, action, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Bindings returns the key bindings of a context, merging the config into
+// its preset, in the order of KeyActions. Actions without keys are left
+// out. It may be called on a nil config for the default bindings.
+func (k *KeysConfig) Bindings(context KeyContext) []KeyBinding {
+	preset := KeyPresets[KeyPresetDefault]
+	if k != nil && k.Preset != "" {
+		preset = KeyPresets[k.Preset]
+	}
+	own := k.context(context)
+
+	// Keys bound by the config are taken away from the preset
+	taken := make(map[string]bool)
+	for _, keys := range own {
+		for _, key := range keys {
+			if name, err := NormalizeKey(key); err == nil {
+				taken[name] = true
+			}
+		}
+	}
+
+	var bindings []KeyBinding
+	for _, action := range KeyActions[context] {
+		var keys []string
+		if configured, exists := own[action]; exists {
+			for _, key := range configured {
+				if name, err := NormalizeKey(key); err == nil && !contains(keys, name) {
+					keys = append(keys, name)
+				}
+			}
+		} else {
+			for _, key := range preset[context][action] {
+				if !taken[key] {
+					keys = append(keys, key)
+				}
+			}
+		}
+		if len(keys) > 0 {
+			bindings = append(bindings, KeyBinding{Action: action, Keys: keys})
+		}
+	}
+	return bindings
+}
+
+// keyNames are the names of keys that do not type a character
+var keyNames = map[string]bool{
+	"enter": true, "esc": true, "tab": true, "backtab": true, "backspace": true,
+	"delete": true, "insert": true, "home": true, "end": true, "pgup": true,
+	"pgdn": true, "up": true, "down": true, "left": true, "right": true,
+	"space": true,
+}
+
+// keyAliases are other spellings of key names
+var keyAliases = map[string]string{
+	"escape": "esc", "return": "enter", "del": "delete", "ins": "insert",
+	"pageup": "pgup", "pagedown": "pgdn", "pgdown": "pgdn",
+}
+
+// keyModifiers are the modifiers a key may have, in canonical order
+var keyModifiers = []string{"ctrl", "alt", "shift"}
+
+// NormalizeKey returns the canonical name of a key, such as "q", "N",
+// "ctrl+f", "alt+enter" or "pgup". Names of special keys and modifiers
+// ignore case and modifiers may be joined with "+" or "-", so "Ctrl-F" and
+// "ctrl+f" are the same key.
+func NormalizeKey(key string) (string, error) {
+	mods := make(map[string]bool)
+	base := key
+	for {
+		mod, rest, found := cutKeyModifier(base)
+		if !found {
+			break
+		}
+		mods[mod] = true
+		base = rest
+	}
+
+	if utf8.RuneCountInString(base) == 1 {
+		r, _ := utf8.DecodeRuneInString(base)
+		switch {
+		case r == ' ':
+			base = "space"
+		case mods["ctrl"]:
+			if r > unicode.MaxASCII || !unicode.IsLetter(r) {
+				return "", fmt.Errorf("key %q: ctrl only combines with letters", key)
+			}
+			base = string(unicode.ToLower(r))
+		case mods["shift"]:
+			return "", fmt.Errorf("key %q: write the shifted character instead", key)
+		}
+	} else {
+		name := strings.ToLower(base)
+		if alias, exists := keyAliases[name]; exists {
+			name = alias
+		}
+		if !keyNames[name] && !isFunctionKey(name) {
+			return "", fmt.Errorf("unknown key %q", key)
+		}
+		base = name
+	}
+
+	var b strings.Builder
+	for _, mod := range keyModifiers {
+		if mods[mod] {
+			b.WriteString(mod + "+")
+		}
+	}
+	b.WriteString(base)
+	return b.String(), nil
+}
+
+// cutKeyModifier splits a leading modifier such as "ctrl+" off a key
+func cutKeyModifier(key string) (mod, rest string, found bool) {
+	for _, mod := range keyModifiers {
+		if len(key) > len(mod)+1 && strings.EqualFold(key[:len(mod)], mod) && strings.ContainsRune("+-", rune(key[len(mod)])) {
+			return mod, key[len(mod)+1:], true
+		}
+	}
+	return "", key, false
+}
+
+// isFunctionKey reports whether name is one of f1 to f12
+func isFunctionKey(name string) bool {
+	var n int
+	if _, err := fmt.Sscanf(name, "f%d", &n); err != nil {
+		return false
+	}
+	return n >= 1 && n <= 12 && name == fmt.Sprintf("f%d", n)
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LayoutMode selects how the TUI arranges command panels
+type LayoutMode string
+
+const (
+	// LayoutGrid arranges panels in a grid of roughly square cells
+	LayoutGrid LayoutMode = "grid"
+	// LayoutStack stacks panels from top to bottom
+	LayoutStack LayoutMode = "stack"
+	// LayoutSplit puts panels side by side
+	LayoutSplit LayoutMode = "split"
+	// LayoutTabs shows one panel at a time with a tab bar
+	LayoutTabs LayoutMode = "tabs"
+	// LayoutCustom uses the tree of the layout config
+	LayoutCustom LayoutMode = "custom"
+)
+
+// LayoutModes lists the layout modes in the order they are cycled through
+var LayoutModes = []LayoutMode{LayoutGrid, LayoutStack, LayoutSplit, LayoutTabs, LayoutCustom}
+
+// Layout directions of a LayoutNode
+const (
+	// DirectionRow places items next to each other, left to right
+	DirectionRow = "row"
+	// DirectionColumn places items below each other, top to bottom
+	DirectionColumn = "column"
+)
+
+// LayoutConfig describes how the TUI arranges command panels
+type LayoutConfig struct {
+	Mode LayoutMode  `yaml:"mode,omitempty"`
+	Tree *LayoutNode `yaml:"tree,omitempty"`
+}
+
+// LayoutNode is a node of a custom layout. A node shows either a command,
+// all commands of a set, or a row or column of other nodes.
+type LayoutNode struct {
+	Command   string       `yaml:"command,omitempty"`
+	Set       string       `yaml:"set,omitempty"`
+	Direction string       `yaml:"direction,omitempty"`
+	Items     []LayoutNode `yaml:"items,omitempty"`
+	// Weight is the relative size of the node in its parent, 1 by default
+	Weight int `yaml:"weight,omitempty"`
+}
+
+// validate checks the layout against the commands and sets of the config
+func (l *LayoutConfig) validate(commands map[string]string, sets map[string]CommandSet) error {
+	switch l.Mode {
+	case "", LayoutGrid, LayoutStack, LayoutSplit, LayoutTabs:
+	case LayoutCustom:
+		if l.Tree == nil {
+			return fmt.Errorf("layout mode custom needs a tree")
+		}
+	default:
+		return fmt.Errorf("unknown layout mode %q", l.Mode)
+	}
+
+	if l.Tree != nil {
+		return l.Tree.validate(commands, sets)
+	}
+	return nil
+}
+
+// validate checks a node and its children
+func (n *LayoutNode) validate(commands map[string]string, sets map[string]CommandSet) error {
+	kinds := 0
+	for _, set := range []bool{n.Command != "", n.Set != "", len(n.Items) > 0} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("layout node must have exactly one of command, set or items")
+	}
+	if n.Weight < 0 {
+		return fmt.Errorf("layout node has negative weight %d", n.Weight)
+	}
+
+	switch {
+	case n.Command != "":
+		if _, exists := commands[n.Command]; !exists {
+			return fmt.Errorf("layout refers to unknown command %q", n.Command)
+		}
+	case n.Set != "":
+		if _, exists := sets[n.Set]; !exists {
+			return fmt.Errorf("layout refers to unknown command set %q", n.Set)
+		}
+	default:
+		switch n.Direction {
+		case "", DirectionRow, DirectionColumn:
+		default:
+			return fmt.Errorf("layout node has unknown direction %q", n.Direction)
+		}
+		for i := range n.Items {
+			if err := n.Items[i].validate(commands, sets); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SaveLayoutMode sets layout.mode in a config file. Only that value is
+// changed, the rest of the file, including comments, is kept.
+func SaveLayoutMode(filename string, mode LayoutMode) error {
+	return editFile(filename, func(root *yaml.Node) error {
+		layout := mappingValue(root, "layout")
+		if layout == nil || layout.Kind != yaml.MappingNode {
+			layout = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingValue(root, "layout", layout)
+		}
+		setMappingValue(layout, "mode", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(mode)})
+		return nil
+	})
+}
+
+// editFile changes the YAML node tree of a config file with edit and
+// writes it back with the indentation of the file, so whatever edit leaves
+// alone, including comments, is kept. edit gets the top-level mapping.
+func editFile(filename string, edit func(root *yaml.Node) error) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s is not a mapping", filename)
+	}
+	if err := edit(doc.Content[0]); err != nil {
+		return err
+	}
+
+	// Keep the indentation of the file
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(detectIndent(data))
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	encoder.Close()
+
+	if err := os.WriteFile(filename, out.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// detectIndent returns the indentation of the first indented line of a
+// YAML file, or 4 like yaml.Marshal if there is none
+func detectIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if indent := len(line) - len(trimmed); indent >= 2 && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			if indent > 8 {
+				break
+			}
+			return indent
+		}
+	}
+	return 4
+}
+
+// setMappingValue replaces the value of key in a mapping node, or appends
+// the key if it is missing
+func setMappingValue(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			// Keep comments attached to the old value
+			value.LineComment = mapping.Content[i+1].LineComment
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		value)
+}
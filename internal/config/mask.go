@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// DefaultMaskEnv are the built-in patterns of environment variable names
+// whose values are masked in output
+var DefaultMaskEnv = []string{"*_TOKEN", "*_SECRET", "*PASSWORD"}
+
+// MaskConfig configures which secrets are masked in command output. The
+// values of environment variables with names matching Env are masked, as
+// is any text matching Patterns; a pattern with groups masks only the
+// groups. The built-in name patterns apply unless Defaults is false.
+type MaskConfig struct {
+	Defaults *bool    `yaml:"defaults,omitempty"`
+	Env      []string `yaml:"env,omitempty"`
+	Patterns []string `yaml:"patterns,omitempty"`
+}
+
+// EnvPatterns returns the name patterns of the variables to mask, after
+// the built-in ones unless they are turned off. A nil config has only the
+// built-in ones.
+func (m *MaskConfig) EnvPatterns() []string {
+	if m == nil {
+		return DefaultMaskEnv
+	}
+
+	var patterns []string
+	if m.Defaults == nil || *m.Defaults {
+		patterns = append(patterns, DefaultMaskEnv...)
+	}
+	return append(patterns, m.Env...)
+}
+
+// MatchesEnv reports whether the name of an environment variable matches
+// one of the patterns, ignoring case
+func MatchesEnv(patterns []string, name string) bool {
+	name = strings.ToUpper(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToUpper(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the name patterns and regular expressions
+func (m *MaskConfig) validate() error {
+	for _, pattern := range m.Env {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("invalid mask env pattern %q", pattern)
+		}
+	}
+	for _, pattern := range m.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil || pattern == "" {
+			return fmt.Errorf("invalid mask pattern %q", pattern)
+		}
+	}
+	return nil
+}
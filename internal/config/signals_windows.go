@@ -0,0 +1,13 @@
+//go:build windows
+
+package config
+
+import (
+	"os"
+)
+
+// Signals maps the signal names accepted by the "signal: NAME" watch action
+// to signals. Windows processes can only be killed.
+var Signals = map[string]os.Signal{
+	"SIGKILL": os.Kill,
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Watch polls the files of the config (see Files) and calls onChange with
+// the reloaded config whenever one of them changes. If the new version
+// cannot be loaded onChange receives the error and the previous files stay
+// watched. Watch returns when ctx is cancelled.
+func (c *Config) Watch(ctx context.Context, interval time.Duration, onChange func(*Config, error)) {
+	if len(c.files) == 0 {
+		return
+	}
+	filename := c.files[0]
+	files := c.Files()
+	stamps := fileStamps(files)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := fileStamps(files)
+		if sameStamps(stamps, current) {
+			continue
+		}
+		stamps = current
+
+		cfg, err := Load(filename)
+		if err != nil {
+			onChange(nil, err)
+			continue
+		}
+
+		// Includes may have been added or removed
+		files = cfg.Files()
+		stamps = fileStamps(files)
+		onChange(cfg, nil)
+	}
+}
+
+// fileStamp identifies a version of a file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// fileStamps returns the current stamp of every file; missing files get
+// a zero stamp
+func fileStamps(files []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		} else {
+			stamps[file] = fileStamp{}
+		}
+	}
+	return stamps
+}
+
+// sameStamps compares two sets of stamps
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, stamp := range a {
+		if other, exists := b[file]; !exists || !other.modTime.Equal(stamp.modTime) || other.size != stamp.size {
+			return false
+		}
+	}
+	return true
+}
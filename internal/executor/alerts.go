@@ -0,0 +1,168 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+)
+
+// alertHookInterval is how often the hook of an alert rule may run for
+// one command, so a flood of matching lines starts only a few hooks
+const alertHookInterval = 10 * time.Second
+
+// maxAlerts is how many alerts a command keeps
+const maxAlerts = 100
+
+// Alert is an output line that matched an alert rule. Seq numbers the
+// alerts of a command.
+type Alert struct {
+	Seq      uint64
+	Rule     string
+	Severity config.AlertSeverity
+	Time     time.Time
+	Text     string
+	Bell     bool
+}
+
+// alertRule is an alert rule with its pattern compiled
+type alertRule struct {
+	config.AlertRule
+	re *regexp.Regexp
+}
+
+// applies reports whether the rule checks the output of the named command
+func (r alertRule) applies(name string) bool {
+	return len(r.Commands) == 0 || contains(r.Commands, name)
+}
+
+// SetAlerts sets the rules output lines are checked against. A nil config
+// uses the built-in rules.
+func (e *Executor) SetAlerts(alerts *config.AlertsConfig) {
+	var rules []alertRule
+	for _, rule := range alerts.AllRules() {
+		// Patterns were checked when the config was loaded
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			continue
+		}
+		if rule.Name == "" {
+			rule.Name = rule.Pattern
+		}
+		if rule.Severity == "" {
+			rule.Severity = config.SeverityError
+		}
+		rules = append(rules, alertRule{rule, re})
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.alerts = rules
+}
+
+// checkAlerts raises an alert on the command for the first rule a line
+// read from it matches, running the hook of the rule if it has one
+func (e *Executor) checkAlerts(cmd *Command, text string) {
+	e.mu.RLock()
+	rules := e.alerts
+	e.mu.RUnlock()
+
+	cmd.mu.RLock()
+	name := cmd.Name
+	cmd.mu.RUnlock()
+
+	for _, rule := range rules {
+		if !rule.applies(name) || !rule.re.MatchString(text) {
+			continue
+		}
+		if masked, due := cmd.raiseAlert(rule, text); due {
+			go e.runAlertHook(cmd, rule, masked)
+		}
+		return
+	}
+}
+
+// raiseAlert records an alert for a line, with its secrets masked, and
+// returns the masked line and whether the hook of the rule is due to run
+func (c *Command) raiseAlert(rule alertRule, text string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	text = c.masker.mask(text)
+	c.alertSeq++
+	c.alerts = append(c.alerts, Alert{
+		Seq:      c.alertSeq,
+		Rule:     rule.Name,
+		Severity: rule.Severity,
+		Time:     time.Now(),
+		Text:     text,
+		Bell:     rule.Bell,
+	})
+	if len(c.alerts) > maxAlerts {
+		c.alerts = append(c.alerts[:0:0], c.alerts[len(c.alerts)-maxAlerts:]...)
+	}
+	if rule.Severity == config.SeverityError || c.alertSeverity == "" {
+		c.alertSeverity = rule.Severity
+	}
+
+	if rule.Hook == "" || time.Since(c.alertHooks[rule.Name]) < alertHookInterval {
+		return text, false
+	}
+	if c.alertHooks == nil {
+		c.alertHooks = make(map[string]time.Time)
+	}
+	c.alertHooks[rule.Name] = time.Now()
+	return text, true
+}
+
+// runAlertHook runs the hook of a rule for a matching line. The hook gets
+// the environment of other hooks plus CMDPOOL_ALERT (the rule),
+// CMDPOOL_ALERT_SEVERITY and CMDPOOL_ALERT_LINE.
+func (e *Executor) runAlertHook(cmd *Command, rule alertRule, text string) {
+	cmd.mu.RLock()
+	dir := cmd.Dir
+	env := append(os.Environ(), cmd.Env...)
+	env = append(env, cmd.hookEnv("alert")...)
+	cmd.mu.RUnlock()
+	env = append(env,
+		"CMDPOOL_ALERT="+rule.Name,
+		"CMDPOOL_ALERT_SEVERITY="+string(rule.Severity),
+		"CMDPOOL_ALERT_LINE="+text)
+
+	if err := runHook(cmd, "alert", rule.Hook, dir, env); err != nil {
+		cmd.addMessage(fmt.Sprintf("[alert] %s failed: %v", rule.Hook, err))
+	}
+}
+
+// AlertState returns the number of alerts raised since they were last
+// acknowledged, the highest severity among them and the sequence number
+// of the newest alert
+func (c *Command) AlertState() (count int, severity config.AlertSeverity, seq uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return int(c.alertSeq - c.alertsAcked), c.alertSeverity, c.alertSeq
+}
+
+// AlertsSince returns the alerts kept that are newer than seq
+func (c *Command) AlertsSince(seq uint64) []Alert {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var alerts []Alert
+	for _, alert := range c.alerts {
+		if alert.Seq > seq {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts
+}
+
+// AckAlerts clears the count of alerts, keeping the alerts themselves
+func (c *Command) AckAlerts() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.alertsAcked = c.alertSeq
+	c.alertSeverity = ""
+}
@@ -0,0 +1,275 @@
+package executor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Default limits of the output a command keeps in memory
+const (
+	DefaultMaxOutputLines = 1000
+	DefaultMaxOutputBytes = 4 << 20
+)
+
+// spillMark is how many spilled lines there are between two offsets kept
+// in memory, trading memory for the lines skipped when reading back
+const spillMark = 64
+
+// outputLimits bound the output a command keeps in memory. With spill
+// set, lines pushed out are written to a temporary file instead of being
+// dropped.
+type outputLimits struct {
+	lines int
+	bytes int
+	spill bool
+}
+
+// outputBuffer is a ring of the newest output lines of a command. It grows
+// up to the line limit and evicts the oldest lines once either the line
+// or the byte limit is reached.
+type outputBuffer struct {
+	limits outputLimits
+	ring   []OutputLine
+	// start is the index of the oldest line in ring, count the number of
+	// lines held and bytes the length of their text
+	start int
+	count int
+	bytes int
+	spill *spillFile
+}
+
+// newOutputBuffer creates an empty buffer with the given limits
+func newOutputBuffer(limits outputLimits) *outputBuffer {
+	return &outputBuffer{limits: limits}
+}
+
+// push adds a line, evicting old lines to stay within the limits. The
+// newest line is always kept, even if it alone exceeds the byte limit.
+func (b *outputBuffer) push(line OutputLine) {
+	for b.count > 0 && (b.count >= b.limits.lines || b.bytes+len(line.Text) > b.limits.bytes) {
+		b.evict()
+	}
+	if b.count == len(b.ring) {
+		b.grow()
+	}
+	b.ring[(b.start+b.count)%len(b.ring)] = line
+	b.count++
+	b.bytes += len(line.Text)
+}
+
+// grow doubles the ring, up to the line limit
+func (b *outputBuffer) grow() {
+	size := min(max(2*len(b.ring), 16), b.limits.lines)
+	ring := make([]OutputLine, size)
+	for i := 0; i < b.count; i++ {
+		ring[i] = b.at(i)
+	}
+	b.ring = ring
+	b.start = 0
+}
+
+// evict drops the oldest line, writing it to the spill file if enabled
+func (b *outputBuffer) evict() {
+	line := b.ring[b.start]
+	if b.limits.spill {
+		b.spillLine(line)
+	}
+	// Release the text for the garbage collector
+	b.ring[b.start] = OutputLine{}
+	b.start = (b.start + 1) % len(b.ring)
+	b.count--
+	b.bytes -= len(line.Text)
+}
+
+// spillLine writes an evicted line to the spill file, creating it first.
+// Spilling stops for good if the file cannot be written.
+func (b *outputBuffer) spillLine(line OutputLine) {
+	if b.spill == nil {
+		spill, err := newSpillFile()
+		if err != nil {
+			b.limits.spill = false
+			return
+		}
+		b.spill = spill
+	}
+	if err := b.spill.write(line); err != nil {
+		b.spill.close()
+		b.spill = nil
+		b.limits.spill = false
+	}
+}
+
+// at returns the line at index i, counted from the oldest
+func (b *outputBuffer) at(i int) OutputLine {
+	return b.ring[(b.start+i)%len(b.ring)]
+}
+
+// len returns the number of lines held in memory
+func (b *outputBuffer) len() int {
+	return b.count
+}
+
+// oldest returns the sequence number of the oldest line kept, spilled or
+// in memory, or 0 if there is none
+func (b *outputBuffer) oldest() uint64 {
+	if b.spill != nil && b.spill.count > 0 {
+		return b.spill.first
+	}
+	if b.count > 0 {
+		return b.at(0).Seq
+	}
+	return 0
+}
+
+// from copies the lines from index i to the newest
+func (b *outputBuffer) from(i int) []OutputLine {
+	lines := make([]OutputLine, b.count-i)
+	for j := range lines {
+		lines[j] = b.at(i + j)
+	}
+	return lines
+}
+
+// setLimits changes the limits, evicting lines that no longer fit
+func (b *outputBuffer) setLimits(limits outputLimits) {
+	if !limits.spill && b.spill != nil {
+		b.spill.close()
+		b.spill = nil
+	}
+	b.limits = limits
+	for b.count > 0 && (b.count > limits.lines || b.bytes > limits.bytes) {
+		b.evict()
+	}
+	if len(b.ring) > limits.lines {
+		lines := b.from(0)
+		b.ring = make([]OutputLine, limits.lines)
+		copy(b.ring, lines)
+		b.start = 0
+	}
+}
+
+// clear drops every line, in memory and spilled
+func (b *outputBuffer) clear() {
+	b.close()
+	b.ring = nil
+	b.start, b.count, b.bytes = 0, 0, 0
+}
+
+// close removes the spill file
+func (b *outputBuffer) close() {
+	if b.spill != nil {
+		b.spill.close()
+		b.spill = nil
+	}
+}
+
+// before returns up to n lines with a sequence number below seq, oldest
+// first, reading spilled lines back from disk
+func (b *outputBuffer) before(seq uint64, n int) ([]OutputLine, error) {
+	// Lines still in memory
+	end := b.count
+	for end > 0 && b.at(end-1).Seq >= seq {
+		end--
+	}
+	start := max(end-n, 0)
+	lines := b.from(start)[:end-start]
+	if len(lines) == n || b.spill == nil {
+		return lines, nil
+	}
+
+	// The rest comes from the spill file, which ends where memory starts
+	to := seq
+	if len(lines) > 0 {
+		to = lines[0].Seq
+	}
+	spilled, err := b.spill.read(to, n-len(lines))
+	if err != nil {
+		return lines, err
+	}
+	return append(spilled, lines...), nil
+}
+
+// spillFile holds the lines evicted from an output buffer, one JSON object
+// per line. Only the offset of every spillMark-th line is kept in memory.
+type spillFile struct {
+	file *os.File
+	w    *bufio.Writer
+	// size is the number of bytes written, first the sequence number of
+	// the first line and count the number of lines
+	size  int64
+	first uint64
+	count int
+	marks []int64
+}
+
+// newSpillFile creates an empty spill file in the temporary directory
+func newSpillFile() (*spillFile, error) {
+	file, err := os.CreateTemp("", "cmdpool-output-*.jsonl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spill file: %w", err)
+	}
+	return &spillFile{file: file, w: bufio.NewWriter(file)}, nil
+}
+
+// write appends a line. Lines are spilled in order, so their sequence
+// numbers follow each other.
+func (s *spillFile) write(line OutputLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	if s.count == 0 {
+		s.first = line.Seq
+	}
+	if s.count%spillMark == 0 {
+		s.marks = append(s.marks, s.size)
+	}
+	if _, err := s.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	s.size += int64(len(data)) + 1
+	s.count++
+	return nil
+}
+
+// read returns up to n spilled lines with a sequence number below seq,
+// oldest first
+func (s *spillFile) read(seq uint64, n int) ([]OutputLine, error) {
+	end := min(int(seq-min(seq, s.first)), s.count)
+	start := max(end-n, 0)
+	if start >= end {
+		return nil, nil
+	}
+	if err := s.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	// Start at the offset kept for the mark before the first line wanted
+	mark := start / spillMark
+	r := bufio.NewReader(io.NewSectionReader(s.file, s.marks[mark], s.size-s.marks[mark]))
+	lines := make([]OutputLine, 0, end-start)
+	for i := mark * spillMark; i < end; i++ {
+		data, err := r.ReadBytes('\n')
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spill file: %w", err)
+		}
+		if i < start {
+			continue
+		}
+		var line OutputLine
+		if err := json.Unmarshal(data, &line); err != nil {
+			return nil, fmt.Errorf("failed to read spill file: %w", err)
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// close closes and removes the file
+func (s *spillFile) close() {
+	s.file.Close()
+	os.Remove(s.file.Name())
+}
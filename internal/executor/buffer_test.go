@@ -0,0 +1,182 @@
+package executor
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// texts returns the text of each line
+func texts(lines []OutputLine) []string {
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		result = append(result, line.Text)
+	}
+	return result
+}
+
+// seqs returns the first and last sequence number of lines, or 0 and 0
+func seqs(lines []OutputLine) (first, last uint64) {
+	if len(lines) == 0 {
+		return 0, 0
+	}
+	return lines[0].Seq, lines[len(lines)-1].Seq
+}
+
+// pushLines pushes lines with sequence numbers 1 to n and the text "line <seq>"
+func pushLines(b *outputBuffer, n int) {
+	for seq := 1; seq <= n; seq++ {
+		b.push(OutputLine{Seq: uint64(seq), Text: fmt.Sprintf("line %d", seq)})
+	}
+}
+
+func TestOutputBufferPush(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits outputLimits
+		push   []string
+		want   []string
+	}{
+		{"below limits", outputLimits{lines: 3, bytes: 100}, []string{"a", "b"}, []string{"a", "b"}},
+		{"line limit wraps around", outputLimits{lines: 3, bytes: 100}, []string{"a", "b", "c", "d", "e"}, []string{"c", "d", "e"}},
+		{"wraps around twice", outputLimits{lines: 2, bytes: 100}, []string{"a", "b", "c", "d", "e"}, []string{"d", "e"}},
+		{"byte limit evicts", outputLimits{lines: 10, bytes: 6}, []string{"aa", "bb", "cc", "dd"}, []string{"bb", "cc", "dd"}},
+		{"long line evicts several", outputLimits{lines: 10, bytes: 6}, []string{"a", "b", "c", "dddd"}, []string{"b", "c", "dddd"}},
+		{"newest line kept over the byte limit", outputLimits{lines: 10, bytes: 4}, []string{"a", "toolong"}, []string{"toolong"}},
+		{"grows past the first ring size", outputLimits{lines: 40, bytes: 1000}, make([]string, 20), make([]string, 20)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newOutputBuffer(tt.limits)
+			for i, text := range tt.push {
+				b.push(OutputLine{Seq: uint64(i + 1), Text: text})
+			}
+			if got := texts(b.from(0)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+			if b.len() != len(tt.want) {
+				t.Errorf("len() = %d, want %d", b.len(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestOutputBufferSetLimits(t *testing.T) {
+	b := newOutputBuffer(outputLimits{lines: 10, bytes: 1000})
+	pushLines(b, 8)
+
+	b.setLimits(outputLimits{lines: 3, bytes: 1000})
+	if got, want := texts(b.from(0)), []string{"line 6", "line 7", "line 8"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lines after shrinking = %q, want %q", got, want)
+	}
+
+	// The smaller ring keeps working
+	b.push(OutputLine{Seq: 9, Text: "line 9"})
+	if got, want := texts(b.from(0)), []string{"line 7", "line 8", "line 9"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lines after push = %q, want %q", got, want)
+	}
+}
+
+func TestOutputBufferBefore(t *testing.T) {
+	tests := []struct {
+		name        string
+		spill       bool
+		seq         uint64
+		n           int
+		first, last uint64
+	}{
+		{"newest in memory", false, 201, 3, 198, 200},
+		{"more than in memory", false, 201, 10, 196, 200},
+		{"older than memory", false, 150, 5, 0, 0},
+		{"newest with spill", true, 201, 3, 198, 200},
+		{"memory and spill", true, 201, 10, 191, 200},
+		{"only spilled", true, 100, 5, 95, 99},
+		{"across spill marks", true, 140, 80, 60, 139},
+		{"oldest spilled", true, 3, 10, 1, 2},
+		{"nothing older", true, 1, 10, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newOutputBuffer(outputLimits{lines: 5, bytes: 1000, spill: tt.spill})
+			t.Cleanup(b.close)
+			pushLines(b, 200)
+
+			lines, err := b.before(tt.seq, tt.n)
+			if err != nil {
+				t.Fatalf("before() error = %v", err)
+			}
+			if first, last := seqs(lines); first != tt.first || last != tt.last {
+				t.Errorf("before(%d, %d) = lines %d-%d, want %d-%d", tt.seq, tt.n, first, last, tt.first, tt.last)
+			}
+			for i, line := range lines {
+				if want := fmt.Sprintf("line %d", line.Seq); line.Text != want || (i > 0 && line.Seq != lines[i-1].Seq+1) {
+					t.Fatalf("line %d = %d %q, want consecutive lines", i, line.Seq, line.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestOutputBufferOldest(t *testing.T) {
+	b := newOutputBuffer(outputLimits{lines: 5, bytes: 1000})
+	if got := b.oldest(); got != 0 {
+		t.Errorf("oldest() of an empty buffer = %d, want 0", got)
+	}
+	pushLines(b, 20)
+	if got := b.oldest(); got != 16 {
+		t.Errorf("oldest() = %d, want 16", got)
+	}
+
+	spilling := newOutputBuffer(outputLimits{lines: 5, bytes: 1000, spill: true})
+	t.Cleanup(spilling.close)
+	pushLines(spilling, 20)
+	if got := spilling.oldest(); got != 1 {
+		t.Errorf("oldest() with spill = %d, want 1", got)
+	}
+
+	spilling.clear()
+	if got := spilling.oldest(); got != 0 {
+		t.Errorf("oldest() after clear = %d, want 0", got)
+	}
+}
+
+func TestOutputSince(t *testing.T) {
+	tests := []struct {
+		name  string
+		add   int
+		clear bool
+		seq   uint64
+		want  []string
+		next  uint64
+		reset bool
+	}{
+		{"from the start", 3, false, 0, []string{"line 1", "line 2", "line 3"}, 3, false},
+		{"new lines", 3, false, 1, []string{"line 2", "line 3"}, 3, false},
+		{"up to date", 3, false, 3, []string{}, 3, false},
+		{"evicted lines reset", 8, false, 1, []string{"line 4", "line 5", "line 6", "line 7", "line 8"}, 8, true},
+		{"oldest kept follows", 8, false, 3, []string{"line 4", "line 5", "line 6", "line 7", "line 8"}, 8, false},
+		{"cleared output resets", 3, true, 3, []string{}, 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &Command{output: newOutputBuffer(outputLimits{lines: 5, bytes: 1000})}
+			for i := 1; i <= tt.add; i++ {
+				cmd.addOutput(fmt.Sprintf("line %d", i))
+			}
+			if tt.clear {
+				cmd.clearOutput()
+			}
+
+			lines, next, reset := cmd.OutputSince(tt.seq)
+			if got := texts(lines); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+			if next != tt.next || reset != tt.reset {
+				t.Errorf("next, reset = %d, %v, want %d, %v", next, reset, tt.next, tt.reset)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package executor
+
+import (
+	"time"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+)
+
+// schedule computes the fire times of a scheduled command
+type schedule interface {
+	// Next returns the first fire time after t, or the zero time if
+	// there is none
+	Next(t time.Time) time.Time
+}
+
+// everySchedule fires at a fixed interval
+type everySchedule struct {
+	interval time.Duration
+}
+
+// Next returns t plus the interval
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+// parseSchedule builds the schedule of a command from either a cron
+// expression or an interval
+func parseSchedule(expr string, every time.Duration) (schedule, error) {
+	if every > 0 {
+		return everySchedule{interval: every}, nil
+	}
+	cron, err := config.ParseCron(expr)
+	if err != nil {
+		return nil, err
+	}
+	return cron, nil
+}
@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// 2024-01-01 is a Monday
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name  string
+		expr  string
+		every time.Duration
+		from  time.Time
+		want  time.Time
+	}{
+		{"every minute", "* * * * *", 0, at(1, 1, 10, 30).Add(15 * time.Second), at(1, 1, 10, 31)},
+		{"minute step", "*/15 * * * *", 0, at(1, 1, 10, 31), at(1, 1, 10, 45)},
+		{"step wraps to the next hour", "*/15 * * * *", 0, at(1, 1, 10, 45), at(1, 1, 11, 0)},
+		{"daily", "0 3 * * *", 0, at(1, 1, 10, 0), at(1, 2, 3, 0)},
+		{"range with step", "30 9-17/4 * * *", 0, at(1, 1, 10, 0), at(1, 1, 13, 30)},
+		{"list", "0 0 1,15 * *", 0, at(1, 2, 0, 0), at(1, 15, 0, 0)},
+		{"sunday as 0", "0 0 * * 0", 0, at(1, 1, 0, 0), at(1, 7, 0, 0)},
+		{"sunday as 7", "0 0 * * 7", 0, at(1, 1, 0, 0), at(1, 7, 0, 0)},
+		{"weekday range", "0 9 * * 1-5", 0, at(1, 5, 10, 0), at(1, 8, 9, 0)},
+		{"day of month or week", "0 0 13 * 5", 0, at(1, 1, 0, 0), at(1, 5, 0, 0)},
+		{"day of month or week, month day first", "0 0 13 * 5", 0, at(1, 12, 0, 0), at(1, 13, 0, 0)},
+		{"day of month step and week", "0 0 */2 * 1", 0, at(1, 1, 0, 0), at(1, 15, 0, 0)},
+		{"day of week step and month", "0 0 1 * */3", 0, at(1, 1, 0, 0), at(5, 1, 0, 0)},
+		{"month range", "0 12 * 1-3 *", 0, at(4, 1, 0, 0), time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", 0, at(3, 1, 0, 0), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"never", "0 0 30 2 *", 0, at(1, 1, 0, 0), time.Time{}},
+		{"descriptor", "@hourly", 0, at(1, 1, 10, 30), at(1, 1, 11, 0)},
+		{"spaces around", "  @daily ", 0, at(1, 1, 10, 30), at(1, 2, 0, 0)},
+		{"interval", "", 90 * time.Second, at(1, 1, 10, 30), at(1, 1, 10, 31).Add(30 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sched, err := parseSchedule(tt.expr, tt.every)
+			if err != nil {
+				t.Fatalf("parseSchedule(%q) error = %v", tt.expr, err)
+			}
+			if got := sched.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"61 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"@weekdays",
+	}
+
+	for _, expr := range tests {
+		if _, err := parseSchedule(expr, 0); err == nil {
+			t.Errorf("parseSchedule(%q) succeeded, want an error", expr)
+		}
+	}
+}
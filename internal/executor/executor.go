@@ -1,45 +1,146 @@
 package executor
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"sync"
 	"time"
+
+	"github.com/pashkov256/cmdpool/internal/config"
 )
 
+// outputDrainDelay is how long output is still read after a command
+// exited, while children it started keep the output open
+const outputDrainDelay = 2 * time.Second
+
+// restartDelay is the pause between an exit and an automatic restart
+const restartDelay = time.Second
+
 // Command represents a running command
 type Command struct {
-	ID          string
-	Name        string
-	Command     string
-	Dir         string
-	Status      CommandStatus
-	Output      []string
-	Error       error
-	StartTime   time.Time
-	EndTime     time.Time
-	Process     *os.Process
-	AutoRestart bool
+	ID         string
+	Name       string
+	Command    string
+	Dir        string
+	Env        []string
+	Status     CommandStatus
+	Error      error
+	StartTime  time.Time
+	EndTime    time.Time
+	Process    *os.Process
+	Restart    config.RestartPolicy
+	DependsOn  []string
+	Probe      string
+	Color      string
+	Restarts   int
+	ExitCode   *int
+	Ready      bool
+	Watch      *config.WatchConfig
+	Schedule   string
+	Every      time.Duration
+	Overlap    config.OverlapPolicy
+	NextRun    time.Time
+	LastRun    time.Time
+	LastResult CommandStatus
+	Hooks      *config.Hooks
+	Pty        bool
+	Format     config.LogFormat
+	// Errors and Warnings count the output lines with these levels
+	Errors   int
+	Warnings int
+	// ReadError is why reading the output of the last run stopped early
+	ReadError error
+	// alerts are the newest alerts raised by output lines. alertSeq
+	// numbers them, alertsAcked is the last one acknowledged and
+	// alertSeverity the highest severity since then. alertHooks is when
+	// the hook of each rule last ran.
+	alerts        []Alert
+	alertSeq      uint64
+	alertsAcked   uint64
+	alertSeverity config.AlertSeverity
+	alertHooks    map[string]time.Time
+	// group is the command set the command belongs to, if any, and step
+	// its position in the set
+	group *Group
+	step  int
+	// entry is the definition the command was created from
+	entry config.CommandEntry
+	// output holds the newest output lines and seq is the sequence number
+	// of the newest one
+	output      *outputBuffer
+	seq         uint64
+	masker      *masker
+	rerun       string
+	runNow      bool
+	restarting  bool
+	cancel      context.CancelFunc
+	watchCancel context.CancelFunc
+	done        chan struct{}
 	mu          sync.RWMutex
 }
 
+// Stream is where an output line came from
+type Stream string
+
+const (
+	StreamStdout Stream = "stdout"
+	StreamStderr Stream = "stderr"
+	// StreamSystem marks lines written by cmdpool itself, such as restart
+	// notices and hook output
+	StreamSystem Stream = "system"
+)
+
+// OutputLine is a line of command output. Seq numbers the lines of a
+// command in the order they were read, across all streams, and Time is
+// when the line was read. Lines of commands with a log format have the
+// level they were logged with.
+type OutputLine struct {
+	Seq    uint64
+	Stream Stream
+	Time   time.Time
+	Level  Level `json:",omitempty"`
+	Text   string
+}
+
+// TimeFormat is how the time of an output line is written
+const TimeFormat = "15:04:05.000"
+
 // CommandStatus represents the status of a command
 type CommandStatus string
 
 const (
-	StatusPending CommandStatus = "pending"
-	StatusRunning CommandStatus = "running"
-	StatusDone    CommandStatus = "done"
-	StatusFailed  CommandStatus = "failed"
-	StatusStopped CommandStatus = "stopped"
+	StatusPending   CommandStatus = "pending"
+	StatusRunning   CommandStatus = "running"
+	StatusDone      CommandStatus = "done"
+	StatusFailed    CommandStatus = "failed"
+	StatusStopped   CommandStatus = "stopped"
+	StatusSkipped   CommandStatus = "skipped"
+	StatusScheduled CommandStatus = "scheduled"
 )
 
+// Finished reports whether a command with this status has stopped running
+func (s CommandStatus) Finished() bool {
+	switch s {
+	case StatusDone, StatusFailed, StatusStopped, StatusSkipped:
+		return true
+	}
+	return false
+}
+
 // Executor manages multiple command executions
 type Executor struct {
 	commands map[string]*Command
+	order    []string
+	groups   []*Group
+	hooks    *config.Hooks
+	alerts   []alertRule
+	masking  masking
+	maxLine  int
+	limits   outputLimits
 	mu       sync.RWMutex
 	ctx      context.Context
 	cancel   context.CancelFunc
@@ -48,57 +149,248 @@ type Executor struct {
 // NewExecutor creates a new command executor
 func NewExecutor() *Executor {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Executor{
+	e := &Executor{
 		commands: make(map[string]*Command),
+		limits:   outputLimits{lines: DefaultMaxOutputLines, bytes: DefaultMaxOutputBytes},
 		ctx:      ctx,
 		cancel:   cancel,
 	}
+	e.SetAlerts(nil)
+	e.SetMasking(nil)
+	return e
+}
+
+// SetMaxLineLength sets the longest output line kept, in bytes; longer
+// lines are truncated. Zero uses DefaultMaxLineLength.
+func (e *Executor) SetMaxLineLength(max int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.maxLine = max
+}
+
+// SetOutputLimits sets how many lines and bytes of output every command
+// keeps in memory; zero uses the defaults. With spill set, older lines are
+// moved to a temporary file and can still be read with OutputBefore.
+func (e *Executor) SetOutputLimits(lines, bytes int, spill bool) {
+	if lines <= 0 {
+		lines = DefaultMaxOutputLines
+	}
+	if bytes <= 0 {
+		bytes = DefaultMaxOutputBytes
+	}
+	limits := outputLimits{lines: lines, bytes: bytes, spill: spill}
+
+	e.mu.Lock()
+	e.limits = limits
+	e.mu.Unlock()
+
+	for _, cmd := range e.List() {
+		cmd.mu.Lock()
+		cmd.output.setLimits(limits)
+		cmd.mu.Unlock()
+	}
+}
+
+// MaxOutputLines returns how many output lines a command keeps in memory
+func (e *Executor) MaxOutputLines() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.limits.lines
 }
 
 // RunCommands executes multiple commands simultaneously
 func (e *Executor) RunCommands(commands []string) error {
 	var wg sync.WaitGroup
 
-	for i, cmdStr := range commands {
+	for _, cmdStr := range commands {
+		cmd := e.Start(config.CommandEntry{Name: cmdStr, Run: cmdStr, Dir: "."})
 		wg.Add(1)
-		go func(id int, command string) {
+		go func() {
 			defer wg.Done()
-			e.runCommand(fmt.Sprintf("cmd_%d", id), command, ".", false)
-		}(i, cmdStr)
+			cmd.Wait()
+		}()
 	}
 
 	wg.Wait()
 	return nil
 }
 
-// RunCommand executes a single command (public method)
+// RunCommand executes a single command and waits for it to finish
 func (e *Executor) RunCommand(id, command, dir string, autoRestart bool) {
-	e.runCommand(id, command, dir, autoRestart)
+	restart := config.RestartNever
+	if autoRestart {
+		restart = config.RestartOnFailure
+	}
+
+	cmd := e.Start(config.CommandEntry{Name: id, Run: command, Dir: dir, Restart: restart})
+	cmd.Wait()
 }
 
-// runCommand executes a single command (private implementation)
-func (e *Executor) runCommand(id, command, dir string, autoRestart bool) {
+// Start registers a command built from a config entry and runs it in the
+// background. The entry name is used as the command ID.
+func (e *Executor) Start(entry config.CommandEntry) *Command {
+	cmd := e.register(entry)
+	e.launch(cmd)
+	return cmd
+}
+
+// register creates a command from an entry and adds it to the executor
+func (e *Executor) register(entry config.CommandEntry) *Command {
+	e.mu.Lock()
 	cmd := &Command{
-		ID:          id,
-		Name:        command,
-		Command:     command,
-		Dir:         dir,
-		Status:      StatusPending,
-		Output:      make([]string, 0),
-		AutoRestart: autoRestart,
-		StartTime:   time.Now(),
+		Status:    StatusPending,
+		StartTime: time.Now(),
+		output:    newOutputBuffer(e.limits),
 	}
+	e.mu.Unlock()
+	cmd.apply(entry)
+	e.updateMasker(cmd)
 
 	e.mu.Lock()
-	e.commands[id] = cmd
+	if _, exists := e.commands[cmd.ID]; !exists {
+		e.order = append(e.order, cmd.ID)
+	}
+	e.commands[cmd.ID] = cmd
 	e.mu.Unlock()
 
-	// Execute command
-	e.executeCommand(cmd)
+	return cmd
+}
+
+// apply copies the definition of an entry into the command
+func (c *Command) apply(entry config.CommandEntry) {
+	restart := entry.Restart
+	if restart == "" {
+		restart = config.RestartNever
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ID = entry.Name
+	c.Name = entry.Name
+	c.Command = entry.Run
+	c.Dir = entry.Dir
+	c.Env = entry.Env
+	c.Restart = restart
+	c.DependsOn = entry.DependsOn
+	c.Probe = entry.Probe
+	c.Color = entry.Color
+	c.Watch = entry.Watch
+	c.Schedule = entry.Schedule
+	c.Every = entry.Every
+	c.Overlap = entry.Overlap
+	c.Hooks = entry.Hooks
+	c.Pty = entry.Pty
+	c.Format = entry.Format
+	c.entry = entry
+}
+
+// launch starts the supervision goroutine of a command
+func (e *Executor) launch(cmd *Command) {
+	ctx, cancel := context.WithCancel(e.ctx)
+	done := make(chan struct{})
+
+	cmd.mu.Lock()
+	cmd.cancel = cancel
+	cmd.done = done
+	cmd.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		defer cancel()
+		if cmd.isScheduled() {
+			e.runSchedule(ctx, cmd)
+		} else {
+			e.supervise(ctx, cmd)
+		}
+	}()
+
+	e.startWatch(cmd)
+}
+
+// supervise waits for dependencies, runs the command and applies its
+// restart policy until it finishes for good or is stopped
+func (e *Executor) supervise(ctx context.Context, cmd *Command) {
+	if err := e.waitForDependencies(ctx, cmd); err != nil {
+		cmd.setStopped()
+		return
+	}
+
+	for {
+		e.executeCommand(ctx, cmd)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A watched file changed while the command was running
+		if reason := cmd.takeRerun(); reason != "" {
+			cmd.addMessage(reason)
+			cmd.reset()
+			continue
+		}
+
+		if !cmd.shouldRestart() {
+			return
+		}
+
+		cmd.addMessage(fmt.Sprintf("[cmdpool] restarting in %s (%s)", restartDelay, cmd.GetStatus()))
+		select {
+		case <-ctx.Done():
+			cmd.setStopped()
+			return
+		case <-time.After(restartDelay):
+		}
+
+		cmd.mu.Lock()
+		cmd.Restarts++
+		cmd.Error = nil
+		cmd.Status = StatusPending
+		cmd.mu.Unlock()
+	}
+}
+
+// waitForDependencies blocks until every command listed in DependsOn is ready
+func (e *Executor) waitForDependencies(ctx context.Context, cmd *Command) error {
+	for _, dep := range cmd.DependsOn {
+		announced := false
+		for {
+			if other := e.getCommand(dep); other != nil && other.isReady() {
+				break
+			}
+			if !announced {
+				cmd.addMessage(fmt.Sprintf("[cmdpool] waiting for %s", dep))
+				announced = true
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(200 * time.Millisecond):
+			}
+		}
+	}
+	return nil
+}
+
+// executeCommand runs the command once, surrounded by its hooks
+func (e *Executor) executeCommand(ctx context.Context, cmd *Command) {
+	if err := e.runHooks(cmd, config.HookPreStart); err != nil && e.hooksBlocking(cmd) {
+		cmd.setError(err)
+	} else {
+		e.runProcess(ctx, cmd)
+	}
+
+	switch cmd.GetStatus() {
+	case StatusDone:
+		e.runHooks(cmd, config.HookOnSuccess)
+	case StatusFailed:
+		e.runHooks(cmd, config.HookOnFailure)
+	}
+	e.runHooks(cmd, config.HookPostStop)
 }
 
-// executeCommand runs the actual command
-func (e *Executor) executeCommand(cmd *Command) {
+// runProcess runs the actual command
+func (e *Executor) runProcess(ctx context.Context, cmd *Command) {
 	// Parse command and arguments
 	args := parseCommand(cmd.Command)
 	if len(args) == 0 {
@@ -107,61 +399,146 @@ func (e *Executor) executeCommand(cmd *Command) {
 	}
 
 	// Create exec.Cmd
-	execCmd := exec.CommandContext(e.ctx, args[0], args[1:]...)
+	execCmd := exec.CommandContext(ctx, args[0], args[1:]...)
 	execCmd.Dir = cmd.Dir
-
-	// Set up pipes for stdout and stderr
-	stdout, err := execCmd.StdoutPipe()
-	if err != nil {
-		cmd.setError(fmt.Errorf("failed to create stdout pipe: %w", err))
-		return
-	}
-
-	stderr, err := execCmd.StderrPipe()
-	if err != nil {
-		cmd.setError(fmt.Errorf("failed to create stderr pipe: %w", err))
-		return
+	execCmd.Env = append(os.Environ(), cmd.Env...)
+	execCmd.WaitDelay = 2 * time.Second
+
+	// Set up pipes for stdout and stderr, or a terminal for both. The
+	// pipes are made here rather than with StdoutPipe, so Wait does not
+	// close them while output is still being read.
+	var outputs []io.ReadCloser
+	var writers []*os.File
+	defer func() {
+		for _, output := range outputs {
+			output.Close()
+		}
+	}()
+	if cmd.Pty {
+		master, tty, err := openPty()
+		if err != nil {
+			cmd.setError(fmt.Errorf("failed to open pty: %w", err))
+			return
+		}
+		outputs = []io.ReadCloser{master}
+		writers = []*os.File{tty}
+		execCmd.Stdin, execCmd.Stdout, execCmd.Stderr = tty, tty, tty
+		execCmd.SysProcAttr = ptyAttr()
+	} else {
+		for _, stream := range []Stream{StreamStdout, StreamStderr} {
+			r, w, err := os.Pipe()
+			if err != nil {
+				for _, writer := range writers {
+					writer.Close()
+				}
+				cmd.setError(fmt.Errorf("failed to create %s pipe: %w", stream, err))
+				return
+			}
+			outputs = append(outputs, r)
+			writers = append(writers, w)
+		}
+		execCmd.Stdout, execCmd.Stderr = writers[0], writers[1]
 	}
 
 	// Start command
-	if err := execCmd.Start(); err != nil {
+	err := execCmd.Start()
+	// Only the command keeps the pipes or the terminal open, so reading
+	// ends when it exits
+	for _, writer := range writers {
+		writer.Close()
+	}
+	if err != nil {
 		cmd.setError(fmt.Errorf("failed to start command: %w", err))
 		return
 	}
 
+	cmd.mu.Lock()
 	cmd.Process = execCmd.Process
 	cmd.Status = StatusRunning
+	cmd.StartTime = time.Now()
+	cmd.EndTime = time.Time{}
+	cmd.ExitCode = nil
+	cmd.Ready = cmd.Probe == ""
+	cmd.mu.Unlock()
+
+	if cmd.Probe != "" {
+		go runProbe(ctx, cmd)
+	}
+
+	// Read output in separate goroutines; a pty has a single output
+	e.mu.RLock()
+	maxLine := e.maxLine
+	e.mu.RUnlock()
+
+	// Every line is checked against the alert rules once it is stored
+	emitStdout := func(line string) {
+		cmd.addOutput(line)
+		e.checkAlerts(cmd, line)
+	}
+	emitStderr := func(line string) {
+		cmd.addErrorOutput(line)
+		e.checkAlerts(cmd, line)
+	}
 
-	// Read output in separate goroutines
 	var wg sync.WaitGroup
-	wg.Add(2)
+	wg.Add(len(outputs))
 
 	go func() {
 		defer wg.Done()
-		scanner := bufio.NewScanner(stdout)
-		for scanner.Scan() {
-			cmd.addOutput(scanner.Text())
-		}
+		cmd.readOutput(StreamStdout, readLines(outputs[0], maxLine, emitStdout))
 	}()
 
-	go func() {
-		defer wg.Done()
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			cmd.addErrorOutput(scanner.Text())
-		}
-	}()
+	if len(outputs) > 1 {
+		go func() {
+			defer wg.Done()
+			cmd.readOutput(StreamStderr, readLines(outputs[1], maxLine, emitStderr))
+		}()
+	}
+
+	// A failing blocking post_start hook takes the command down
+	hookErr := e.runHooks(cmd, config.HookPostStart)
+	if hookErr != nil && e.hooksBlocking(cmd) {
+		execCmd.Process.Kill()
+	} else {
+		hookErr = nil
+	}
 
-	// Wait for command to complete
+	// Wait for the command, then for the rest of its output. Children it
+	// left behind may keep the output open, so reading stops shortly
+	// after the command exited.
 	err = execCmd.Wait()
-	wg.Wait()
+	read := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(read)
+	}()
+	select {
+	case <-read:
+	case <-time.After(outputDrainDelay):
+		for _, output := range outputs {
+			output.Close()
+		}
+		<-read
+	}
 
+	cmd.mu.Lock()
 	cmd.EndTime = time.Now()
+	cmd.Ready = false
+	if execCmd.ProcessState != nil {
+		exitCode := execCmd.ProcessState.ExitCode()
+		cmd.ExitCode = &exitCode
+	}
+	cmd.mu.Unlock()
 
-	if err != nil {
+	switch {
+	case ctx.Err() != nil:
+		cmd.setStopped()
+	case hookErr != nil:
+		cmd.setError(hookErr)
+	case err != nil:
 		cmd.setError(err)
-	} else {
-		cmd.Status = StatusDone
+	default:
+		cmd.setStatus(StatusDone)
 	}
 }
 
@@ -193,6 +570,19 @@ func parseCommand(cmdStr string) []string {
 	return args
 }
 
+// readOutput records why reading a stream of the command stopped. Reading
+// an output closed after a stop, or a terminal after the command exited,
+// is the normal end.
+func (c *Command) readOutput(stream Stream, err error) {
+	if err == nil || errors.Is(err, os.ErrClosed) || ptyClosed(err) {
+		return
+	}
+	c.mu.Lock()
+	c.ReadError = fmt.Errorf("reading %s: %w", stream, err)
+	c.mu.Unlock()
+	c.addMessage(fmt.Sprintf("[cmdpool] stopped reading %s: %v", stream, err))
+}
+
 // setError sets the error status and message
 func (c *Command) setError(err error) {
 	c.mu.Lock()
@@ -201,31 +591,224 @@ func (c *Command) setError(err error) {
 	c.Status = StatusFailed
 }
 
-// addOutput adds a line to the output
-func (c *Command) addOutput(line string) {
+// setStatus sets the command status
+func (c *Command) setStatus(status CommandStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Status = status
+}
+
+// setStopped marks the command as stopped by the user
+func (c *Command) setStopped() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Status = StatusStopped
+	if c.EndTime.IsZero() {
+		c.EndTime = time.Now()
+	}
+}
+
+// shouldRestart reports whether the restart policy asks for another run
+func (c *Command) shouldRestart() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	switch c.Restart {
+	case config.RestartAlways:
+		return true
+	case config.RestartOnFailure:
+		return c.Status == StatusFailed
+	}
+	return false
+}
+
+// isReady reports whether dependents of this command may start
+func (c *Command) isReady() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Status == StatusDone || (c.Status == StatusRunning && c.Ready)
+}
+
+// GetStatus returns the current status of the command
+func (c *Command) GetStatus() CommandStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Status
+}
+
+// Group returns the command set the command belongs to, or nil
+func (c *Command) Group() *Group {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.group
+}
+
+// Step returns the position of the command in its set, starting at 1
+func (c *Command) Step() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.step
+}
+
+// setGroup makes the command the given step of a group
+func (c *Command) setGroup(group *Group, step int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.Output = append(c.Output, line)
+	c.group = group
+	c.step = step
+}
 
-	// Keep only last 1000 lines
-	if len(c.Output) > 1000 {
-		c.Output = c.Output[len(c.Output)-1000:]
+// GetRuntime returns the process ID of the current run (0 if none), its
+// start and end time and how often the command was restarted
+func (c *Command) GetRuntime() (pid int, startTime, endTime time.Time, restarts int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.Process != nil {
+		pid = c.Process.Pid
 	}
+	return pid, c.StartTime, c.EndTime, c.Restarts
 }
 
-// addErrorOutput adds a line to the output (treating stderr as output)
+// launched reports whether the command has been started at least once
+func (c *Command) launched() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.done != nil
+}
+
+// busy reports whether the command is running or about to run again
+func (c *Command) busy() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.restarting || (c.done != nil && !c.Status.Finished())
+}
+
+// setRestarting marks the command as stopped only to be started again
+func (c *Command) setRestarting(restarting bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.restarting = restarting
+}
+
+// Wait blocks until the command has finished and will not be restarted
+func (c *Command) Wait() {
+	c.mu.RLock()
+	done := c.done
+	c.mu.RUnlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+// addOutput adds a line read from stdout to the output
+func (c *Command) addOutput(line string) {
+	c.addLine(StreamStdout, line)
+}
+
+// addErrorOutput adds a line read from stderr to the output
 func (c *Command) addErrorOutput(line string) {
-	c.addOutput("[STDERR] " + line)
+	c.addLine(StreamStderr, line)
 }
 
-// GetOutput returns a copy of the command output
-func (c *Command) GetOutput() []string {
+// addMessage adds a line from cmdpool itself to the output
+func (c *Command) addMessage(line string) {
+	c.addLine(StreamSystem, line)
+}
+
+// addLine adds a line of a stream to the output, numbering it after every
+// line added before. Lines of the command are parsed in its log format,
+// and secrets are masked before the line is stored.
+func (c *Command) addLine(stream Stream, text string) {
+	line := OutputLine{Stream: stream, Time: time.Now(), Text: text}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Format != "" && stream != StreamSystem {
+		line.Level, line.Text = parseLine(c.Format, text)
+		switch line.Level {
+		case LevelError:
+			c.Errors++
+		case LevelWarn:
+			c.Warnings++
+		}
+	}
+	// Masking after parsing also catches secrets that were escaped in JSON
+	line.Text = c.masker.mask(line.Text)
+	c.seq++
+	line.Seq = c.seq
+	c.output.push(line)
+}
+
+// LevelCounts returns the number of error and warning lines since the
+// output was last cleared
+func (c *Command) LevelCounts() (errorCount, warningCount int) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
+	return c.Errors, c.Warnings
+}
 
-	result := make([]string, len(c.Output))
-	copy(result, c.Output)
-	return result
+// GetOutput returns a copy of the output lines kept in memory
+func (c *Command) GetOutput() []OutputLine {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.output.from(0)
+}
+
+// OutputSince returns the output lines added after the line with sequence
+// number seq, and the sequence number of the newest line. If lines after
+// seq are no longer kept, or the output was cleared, reset is true and all
+// lines are returned; the caller should then discard what it has.
+func (c *Command) OutputSince(seq uint64) (lines []OutputLine, next uint64, reset bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Sequence number of the oldest line in memory
+	first := c.seq - uint64(c.output.len()) + 1
+	start := 0
+	switch {
+	case seq+1 < first:
+		reset = true
+	case seq < c.seq:
+		start = int(seq + 1 - first)
+	default:
+		start = c.output.len()
+	}
+	return c.output.from(start), c.seq, reset
+}
+
+// MaxOutputLines returns how many output lines the command keeps in memory
+func (c *Command) MaxOutputLines() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.output.limits.lines
+}
+
+// OutputBefore returns up to n output lines older than the line with
+// sequence number seq, oldest first. Lines no longer in memory are read
+// back from the spill file if spilling is enabled.
+func (c *Command) OutputBefore(seq uint64, n int) ([]OutputLine, error) {
+	// Reading spilled lines flushes the spill file, so take the write lock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.output.before(seq, n)
+}
+
+// clearOutput drops all output lines
+func (c *Command) clearOutput() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.output.clear()
+	c.Errors, c.Warnings = 0, 0
+	// Clearing uses up a sequence number so OutputSince reports a reset
+	c.seq++
+}
+
+// closeOutput removes the spill file of the command
+func (c *Command) closeOutput() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.output.close()
 }
 
 // GetCommands returns all commands
@@ -240,22 +823,83 @@ func (e *Executor) GetCommands() map[string]*Command {
 	return result
 }
 
+// List returns all commands in the order they were first started
+func (e *Executor) List() []*Command {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := make([]*Command, 0, len(e.order))
+	for _, id := range e.order {
+		result = append(result, e.commands[id])
+	}
+	return result
+}
+
+// getCommand returns a command by ID or nil
+func (e *Executor) getCommand(id string) *Command {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.commands[id]
+}
+
 // StopCommand stops a running command
 func (e *Executor) StopCommand(id string) error {
-	e.mu.RLock()
-	cmd, exists := e.commands[id]
-	e.mu.RUnlock()
+	cmd := e.getCommand(id)
+	if cmd == nil {
+		return fmt.Errorf("command %s not found", id)
+	}
+
+	e.stop(cmd)
+	return nil
+}
+
+// stop runs the pre_stop hooks of a running command, stops it and waits
+// until it has finished
+func (e *Executor) stop(cmd *Command) {
+	cmd.mu.RLock()
+	cancel := cmd.cancel
+	cmd.mu.RUnlock()
+
+	if cancel != nil {
+		if cmd.GetStatus() == StatusRunning {
+			e.runHooks(cmd, config.HookPreStop)
+		}
+		cancel()
+		cmd.Wait()
+	}
+}
 
-	if !exists {
+// RemoveCommand stops a command and forgets about it
+func (e *Executor) RemoveCommand(id string) error {
+	cmd := e.getCommand(id)
+	if cmd == nil {
 		return fmt.Errorf("command %s not found", id)
 	}
+	e.stop(cmd)
+	cmd.stopWatch()
+	cmd.closeOutput()
+	group := cmd.Group()
 
-	if cmd.Process != nil {
-		if err := cmd.Process.Kill(); err != nil {
-			return fmt.Errorf("failed to kill process: %w", err)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	delete(e.commands, id)
+	for i, other := range e.order {
+		if other == id {
+			e.order = append(e.order[:i:i], e.order[i+1:]...)
+			break
 		}
-		cmd.Status = StatusStopped
-		cmd.EndTime = time.Now()
+	}
+
+	if group != nil {
+		group.mu.Lock()
+		for i, other := range group.Commands {
+			if other == cmd {
+				group.Commands = append(group.Commands[:i:i], group.Commands[i+1:]...)
+				break
+			}
+		}
+		group.mu.Unlock()
 	}
 
 	return nil
@@ -263,45 +907,89 @@ func (e *Executor) StopCommand(id string) error {
 
 // RestartCommand restarts a command
 func (e *Executor) RestartCommand(id string) error {
-	e.mu.RLock()
-	cmd, exists := e.commands[id]
-	e.mu.RUnlock()
-
-	if !exists {
+	cmd := e.getCommand(id)
+	if cmd == nil {
 		return fmt.Errorf("command %s not found", id)
 	}
 
+	e.restart(cmd, "")
+	return nil
+}
+
+// restart stops a command, clears its output and starts it again. A
+// non-empty reason is shown as the first line of the new output.
+func (e *Executor) restart(cmd *Command, reason string) {
 	// Stop if running
-	if cmd.Status == StatusRunning {
-		if err := e.StopCommand(id); err != nil {
-			return err
-		}
-	}
+	cmd.setRestarting(true)
+	defer cmd.setRestarting(false)
+	e.StopCommand(cmd.ID)
 
 	// Reset command state
+	cmd.reset()
+	cmd.clearOutput()
 	cmd.mu.Lock()
-	cmd.Status = StatusPending
-	cmd.Output = make([]string, 0)
-	cmd.Error = nil
-	cmd.StartTime = time.Now()
-	cmd.EndTime = time.Time{}
-	cmd.Process = nil
+	cmd.runNow = cmd.Schedule != "" || cmd.Every > 0
 	cmd.mu.Unlock()
+	if reason != "" {
+		cmd.addMessage(reason)
+	}
 
 	// Restart
-	go e.executeCommand(cmd)
-	return nil
+	e.launch(cmd)
 }
 
-// Stop stops all running commands
+// reset clears the state of the previous run
+func (c *Command) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Status = StatusPending
+	c.Error = nil
+	c.ReadError = nil
+	c.StartTime = time.Now()
+	c.EndTime = time.Time{}
+	c.Process = nil
+	c.Ready = false
+}
+
+// Stop stops all running commands, waiting for the hooks run when they
+// finish
 func (e *Executor) Stop() {
+	// Give pre_stop hooks a chance to run before anything is killed
+	var wg sync.WaitGroup
+	for _, group := range e.GetGroups() {
+		if group.running() && len(group.hooks.Stage(config.HookPreStop)) > 0 {
+			wg.Add(1)
+			go func(group *Group) {
+				defer wg.Done()
+				e.runSetHooks(group, config.HookPreStop, StatusRunning)
+			}(group)
+		}
+	}
+	for _, cmd := range e.List() {
+		if cmd.GetStatus() == StatusRunning && e.hasHooks(cmd, config.HookPreStop) {
+			wg.Add(1)
+			go func(cmd *Command) {
+				defer wg.Done()
+				e.runHooks(cmd, config.HookPreStop)
+			}(cmd)
+		}
+	}
+	wg.Wait()
+
 	e.cancel()
+	e.Wait()
+	for _, cmd := range e.List() {
+		cmd.closeOutput()
+	}
+}
 
-	e.mu.RLock()
-	for _, cmd := range e.commands {
-		if cmd.Process != nil {
-			cmd.Process.Kill()
-		}
+// Wait blocks until every command has finished and the hooks run after
+// them, including those of command sets, are done
+func (e *Executor) Wait() {
+	for _, cmd := range e.List() {
+		cmd.Wait()
+	}
+	for _, group := range e.GetGroups() {
+		<-group.done
 	}
-	e.mu.RUnlock()
 }
@@ -0,0 +1,201 @@
+package executor
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// exportChunk is how many lines are read at a time while writing output
+const exportChunk = 1000
+
+// FormatLine writes an output line for a saved log: the time it was read,
+// a mark on stderr lines and the level of log lines
+func FormatLine(line OutputLine) string {
+	text := line.Text
+	if line.Level != LevelNone {
+		text = fmt.Sprintf("%-5s %s", line.Level, text)
+	}
+	if line.Stream == StreamStderr {
+		text = "[stderr] " + text
+	}
+	return line.Time.Format(TimeFormat) + " " + text
+}
+
+// WriteOutput writes every output line the command still has, including
+// spilled ones, oldest first with FormatLine. Lines added while writing
+// are left out.
+func (c *Command) WriteOutput(w io.Writer) error {
+	c.mu.RLock()
+	seq, last := c.output.oldest(), c.seq
+	c.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for seq <= last {
+		lines, err := c.OutputBefore(min(seq+exportChunk, last+1), exportChunk)
+		if err != nil {
+			return err
+		}
+		// The output was cleared in the meantime
+		if len(lines) == 0 {
+			break
+		}
+		for _, line := range lines {
+			if line.Seq >= seq {
+				fmt.Fprintln(bw, FormatLine(line))
+			}
+		}
+		seq = lines[len(lines)-1].Seq + 1
+	}
+	return bw.Flush()
+}
+
+// commandInfo describes a command in the metadata of an archive
+type commandInfo struct {
+	Name      string        `json:"name"`
+	Set       string        `json:"set,omitempty"`
+	Command   string        `json:"command"`
+	Dir       string        `json:"dir,omitempty"`
+	Env       []string      `json:"env,omitempty"`
+	Status    CommandStatus `json:"status"`
+	ExitCode  *int          `json:"exit_code,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Restarts  int           `json:"restarts"`
+	StartTime time.Time     `json:"start_time"`
+	EndTime   *time.Time    `json:"end_time,omitempty"`
+	Duration  string        `json:"duration"`
+	Log       string        `json:"log"`
+}
+
+// info returns the metadata of the command, with secrets masked
+func (c *Command) info(log string) commandInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	info := commandInfo{
+		Name:      c.Name,
+		Command:   c.masker.mask(c.Command),
+		Dir:       c.Dir,
+		Status:    c.Status,
+		ExitCode:  c.ExitCode,
+		Restarts:  c.Restarts,
+		StartTime: c.StartTime,
+		Log:       log,
+	}
+	if c.group != nil {
+		info.Set = c.group.Key
+	}
+	for _, v := range c.Env {
+		info.Env = append(info.Env, c.masker.mask(v))
+	}
+	if c.Error != nil {
+		info.Error = c.masker.mask(c.Error.Error())
+	}
+	end := time.Now()
+	if c.Status.Finished() && !c.EndTime.IsZero() {
+		end = c.EndTime
+		info.EndTime = &end
+	}
+	info.Duration = end.Sub(c.StartTime).Round(time.Millisecond).String()
+	return info
+}
+
+// WriteArchive writes a gzipped tar archive of all commands: the output
+// of each in logs/<name>.log and what was run, where, with which
+// environment and how it ended in metadata.json
+func (e *Executor) WriteArchive(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	var infos []commandInfo
+	used := make(map[string]bool)
+	for _, cmd := range e.List() {
+		cmd.mu.RLock()
+		name := logName(cmd.Name, used)
+		cmd.mu.RUnlock()
+
+		if err := writeLog(tw, cmd, name, now); err != nil {
+			return fmt.Errorf("failed to archive output of %s: %w", cmd.Name, err)
+		}
+		infos = append(infos, cmd.info(name))
+	}
+
+	metadata, err := json.MarshalIndent(struct {
+		Created  time.Time     `json:"created"`
+		Commands []commandInfo `json:"commands"`
+	}{now, infos}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(tw, "metadata.json", now, int64(len(metadata)), bytes.NewReader(metadata)); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// writeLog adds the output of a command to an archive. The output goes
+// through a temporary file first, as tar needs its size up front and
+// spilled output may not fit in memory.
+func writeLog(tw *tar.Writer, cmd *Command, name string, modTime time.Time) error {
+	tmp, err := os.CreateTemp("", "cmdpool-export-*.log")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := cmd.WriteOutput(tmp); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return writeFile(tw, name, modTime, size, tmp)
+}
+
+// writeFile adds a file to an archive
+func writeFile(tw *tar.Writer, name string, modTime time.Time, size int64, r io.Reader) error {
+	header := &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: modTime}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.CopyN(tw, r, size)
+	return err
+}
+
+// logName returns the archive path of the log of a command, made safe as
+// a file name and unique among the names used
+func logName(name string, used map[string]bool) string {
+	base := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	if base == "" || base == "." || base == ".." {
+		base = "command"
+	}
+
+	path := "logs/" + base + ".log"
+	for i := 2; used[path]; i++ {
+		path = fmt.Sprintf("logs/%s-%d.log", base, i)
+	}
+	used[path] = true
+	return path
+}
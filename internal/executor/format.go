@@ -0,0 +1,254 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+)
+
+// Level is the severity of a structured log line
+type Level int
+
+const (
+	// LevelNone marks lines without a level, such as plain text
+	LevelNone Level = iota
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the name of the level as shown in front of lines
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return ""
+}
+
+// Keys of structured log lines with a meaning of their own. Time keys are
+// left out as every line already has the time it was read.
+var (
+	levelKeys   = []string{"level", "lvl", "severity", "loglevel"}
+	messageKeys = []string{"msg", "message"}
+	timeKeys    = []string{"time", "ts", "timestamp", "@timestamp", "t"}
+)
+
+// field is a key and value of a structured log line. Nested JSON values
+// are written as they are.
+type field struct {
+	key    string
+	value  string
+	nested bool
+}
+
+// parseLine parses a line in the given format and returns its level and
+// the line as "msg key=value ...". Lines that are not in the format are
+// returned as they are, without a level.
+func parseLine(format config.LogFormat, text string) (Level, string) {
+	var fields []field
+	ok := false
+	switch format {
+	case config.FormatJSON:
+		fields, ok = parseJSON(text)
+	case config.FormatLogfmt:
+		fields, ok = parseLogfmt(text)
+	case config.FormatAuto:
+		if fields, ok = parseJSON(text); !ok {
+			// Any line with an equals sign is logfmt, so only take it if
+			// it looks like a log line
+			fields, ok = parseLogfmt(text)
+			ok = ok && (hasKey(fields, levelKeys) || hasKey(fields, messageKeys))
+		}
+	}
+	if !ok {
+		return LevelNone, text
+	}
+	return renderFields(fields)
+}
+
+// renderFields writes the message first and the other fields after it,
+// leaving out the level and the time
+func renderFields(fields []field) (Level, string) {
+	level := LevelNone
+	var message string
+	var rest []string
+	for _, f := range fields {
+		switch key := strings.ToLower(f.key); {
+		case level == LevelNone && contains(levelKeys, key):
+			level = ParseLevel(f.value)
+		case message == "" && contains(messageKeys, key):
+			message = f.value
+		case contains(timeKeys, key):
+		default:
+			value := f.value
+			if !f.nested {
+				value = quoteValue(value)
+			}
+			rest = append(rest, f.key+"="+value)
+		}
+	}
+
+	if message != "" {
+		rest = append([]string{message}, rest...)
+	}
+	return level, strings.Join(rest, " ")
+}
+
+// ParseLevel reads a level name or a numeric level as used by pino and
+// bunyan. Unknown levels are LevelNone.
+func ParseLevel(name string) Level {
+	if n, err := strconv.Atoi(name); err == nil {
+		switch {
+		case n >= 50:
+			return LevelError
+		case n >= 40:
+			return LevelWarn
+		case n >= 30:
+			return LevelInfo
+		default:
+			return LevelDebug
+		}
+	}
+
+	switch strings.ToLower(name) {
+	case "trace", "debug", "dbg":
+		return LevelDebug
+	case "info", "information", "notice":
+		return LevelInfo
+	case "warn", "warning", "wrn":
+		return LevelWarn
+	case "error", "err", "fatal", "panic", "critical", "crit", "alert", "emergency":
+		return LevelError
+	}
+	return LevelNone
+}
+
+// parseJSON reads the fields of a JSON object line in their order. Nested
+// values are kept as compact JSON.
+func parseJSON(text string) ([]field, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "{") {
+		return nil, false
+	}
+
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	if token, err := dec.Token(); err != nil || token != json.Delim('{') {
+		return nil, false
+	}
+
+	var fields []field
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, _ := token.(string)
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, false
+		}
+		f := field{key: key, value: string(raw)}
+		switch raw[0] {
+		case '"':
+			json.Unmarshal(raw, &f.value)
+		case '{', '[':
+			var compact bytes.Buffer
+			if json.Compact(&compact, raw) == nil {
+				f.value = compact.String()
+			}
+			f.nested = true
+		}
+		fields = append(fields, f)
+	}
+
+	// Nothing may follow the object
+	if token, err := dec.Token(); err != nil || token != json.Delim('}') || dec.More() {
+		return nil, false
+	}
+	return fields, true
+}
+
+// parseLogfmt reads a line of key=value pairs. Values may be quoted; a key
+// without a value is a flag with an empty value.
+func parseLogfmt(text string) ([]field, bool) {
+	var fields []field
+	pairs := 0
+	rest := strings.TrimSpace(text)
+	for rest != "" {
+		end := strings.IndexAny(rest, "= ")
+		if end == 0 {
+			return nil, false
+		}
+		if end < 0 || rest[end] == ' ' {
+			// A bare key
+			if end < 0 {
+				end = len(rest)
+			}
+			fields = append(fields, field{key: rest[:end]})
+			rest = strings.TrimLeft(rest[end:], " ")
+			continue
+		}
+
+		key := rest[:end]
+		rest = rest[end+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, false
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+			if rest != "" && rest[0] != ' ' {
+				return nil, false
+			}
+		} else {
+			value, rest, _ = strings.Cut(rest, " ")
+		}
+		fields = append(fields, field{key: key, value: value})
+		pairs++
+		rest = strings.TrimLeft(rest, " ")
+	}
+	return fields, pairs > 0
+}
+
+// quoteValue quotes a value that would not read back as a single value
+func quoteValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// hasKey reports whether one of the fields has one of the keys
+func hasKey(fields []field, keys []string) bool {
+	for _, f := range fields {
+		if contains(keys, strings.ToLower(f.key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
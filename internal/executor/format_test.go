@@ -0,0 +1,101 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		format config.LogFormat
+		text   string
+		level  Level
+		want   string
+	}{
+		{"json", config.FormatJSON, `{"level":"info","msg":"started","port":8080}`, LevelInfo, "started port=8080"},
+		{"json time left out", config.FormatJSON, `{"time":"2024-01-01T00:00:00Z","level":"warn","msg":"slow"}`, LevelWarn, "slow"},
+		{"json numeric level", config.FormatJSON, `{"level":50,"msg":"boom"}`, LevelError, "boom"},
+		{"json message after fields", config.FormatJSON, `{"user":"bob","message":"login","severity":"ERROR"}`, LevelError, "login user=bob"},
+		{"json nested values", config.FormatJSON, `{"msg":"req","headers":{"a": 1},"ids":[1, 2]}`, LevelNone, `req headers={"a":1} ids=[1,2]`},
+		{"json values quoted", config.FormatJSON, `{"msg":"x","path":"a b","empty":""}`, LevelNone, `x path="a b" empty=""`},
+		{"json escapes", config.FormatJSON, `{"msg":"tab\there \"quoted\""}`, LevelNone, "tab\there \"quoted\""},
+		{"json not an object", config.FormatJSON, `["level","info"]`, LevelNone, `["level","info"]`},
+		{"json trailing text", config.FormatJSON, `{"msg":"a"} extra`, LevelNone, `{"msg":"a"} extra`},
+		{"json broken", config.FormatJSON, `{"msg":`, LevelNone, `{"msg":`},
+		{"json plain text", config.FormatJSON, "plain text", LevelNone, "plain text"},
+		{"logfmt", config.FormatLogfmt, `level=error msg="connection lost" retry=3`, LevelError, "connection lost retry=3"},
+		{"logfmt quoted escapes", config.FormatLogfmt, `msg="say \"hi\"" lvl=debug`, LevelDebug, `say "hi"`},
+		{"logfmt bare key", config.FormatLogfmt, `msg=done verbose`, LevelNone, `done verbose=""`},
+		{"logfmt extra spaces", config.FormatLogfmt, `  level=warn   msg=disk  `, LevelWarn, "disk"},
+		{"logfmt value with equals", config.FormatLogfmt, `msg=x query=a=b`, LevelNone, `x query="a=b"`},
+		{"logfmt unterminated quote", config.FormatLogfmt, `msg="open`, LevelNone, `msg="open`},
+		{"logfmt text after quote", config.FormatLogfmt, `msg="a"b`, LevelNone, `msg="a"b`},
+		{"logfmt starts with equals", config.FormatLogfmt, `=x`, LevelNone, `=x`},
+		{"logfmt no pairs", config.FormatLogfmt, "just words", LevelNone, "just words"},
+		{"auto json", config.FormatAuto, `{"level":"debug","msg":"tick"}`, LevelDebug, "tick"},
+		{"auto logfmt", config.FormatAuto, `level=info msg=ready`, LevelInfo, "ready"},
+		{"auto plain with equals", config.FormatAuto, "a=b c=d", LevelNone, "a=b c=d"},
+		{"auto plain text", config.FormatAuto, "Listening on :8080", LevelNone, "Listening on :8080"},
+		{"unknown level", config.FormatLogfmt, `level=chatty msg=hi`, LevelNone, "hi"},
+		{"first level wins", config.FormatLogfmt, `level=info lvl=error msg=hi`, LevelInfo, "hi lvl=error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, text := parseLine(tt.format, tt.text)
+			if level != tt.level || text != tt.want {
+				t.Errorf("parseLine(%q) = %v, %q, want %v, %q", tt.text, level, text, tt.level, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		want Level
+	}{
+		{"debug", LevelDebug},
+		{"TRACE", LevelDebug},
+		{"Info", LevelInfo},
+		{"notice", LevelInfo},
+		{"warning", LevelWarn},
+		{"ERR", LevelError},
+		{"fatal", LevelError},
+		{"10", LevelDebug},
+		{"30", LevelInfo},
+		{"40", LevelWarn},
+		{"60", LevelError},
+		{"", LevelNone},
+		{"verbose", LevelNone},
+	}
+
+	for _, tt := range tests {
+		if got := ParseLevel(tt.name); got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseLogfmtFields(t *testing.T) {
+	tests := []struct {
+		text string
+		want []field
+		ok   bool
+	}{
+		{`a=1 b="two words" c`, []field{{key: "a", value: "1"}, {key: "b", value: "two words"}, {key: "c"}}, true},
+		{`a= b=2`, []field{{key: "a"}, {key: "b", value: "2"}}, true},
+		{`flag`, []field{{key: "flag"}}, false},
+		{``, nil, false},
+	}
+
+	for _, tt := range tests {
+		fields, ok := parseLogfmt(tt.text)
+		if ok != tt.ok || (ok && !reflect.DeepEqual(fields, tt.want)) {
+			t.Errorf("parseLogfmt(%q) = %+v, %v, want %+v, %v", tt.text, fields, ok, tt.want, tt.ok)
+		}
+	}
+}
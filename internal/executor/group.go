@@ -0,0 +1,347 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+)
+
+// Group tracks the commands started from one command set
+type Group struct {
+	Key             string
+	Name            string
+	Mode            config.RunMode
+	ContinueOnError bool
+	Commands        []*Command
+	step            int
+	// hooks are those of the set, run in dir with env added to the
+	// environment of cmdpool. done is closed once they have all run.
+	hooks  *config.Hooks
+	dir    string
+	env    []string
+	done   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.RWMutex
+}
+
+// Progress returns the current step and the number of steps of the group.
+// For sequential groups the step is the command currently running (or the
+// last one that ran); for other modes it is the number of finished commands.
+func (g *Group) Progress() (step, total int) {
+	cmds := g.List()
+	if g.Mode != config.ModeSequential {
+		for _, cmd := range cmds {
+			if cmd.GetStatus().Finished() {
+				step++
+			}
+		}
+		return step, len(cmds)
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.step, len(cmds)
+}
+
+// List returns the commands of the group. Commands join and leave the
+// group on reload, so Commands is only read through this copy.
+func (g *Group) List() []*Command {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	cmds := make([]*Command, len(g.Commands))
+	copy(cmds, g.Commands)
+	return cmds
+}
+
+// Finished reports whether the last command of the group has finished,
+// which for a sequential group means it got to the end
+func (g *Group) Finished() bool {
+	cmds := g.List()
+	return len(cmds) == 0 || cmds[len(cmds)-1].GetStatus().Finished()
+}
+
+// Active reports whether any command of the group has not finished yet
+func (g *Group) Active() bool {
+	for _, cmd := range g.List() {
+		if !cmd.GetStatus().Finished() {
+			return true
+		}
+	}
+	return false
+}
+
+// running reports whether any command of the group is running or about
+// to run again
+func (g *Group) running() bool {
+	for _, cmd := range g.List() {
+		if cmd.busy() {
+			return true
+		}
+	}
+	return false
+}
+
+// wait blocks until no command of the group is running. Commands that
+// never started, such as the steps after a stopped sequence, are not
+// waited for.
+func (g *Group) wait() {
+	for {
+		for _, cmd := range g.List() {
+			cmd.Wait()
+		}
+		if !g.running() {
+			return
+		}
+		// A command is being restarted
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// result sums up how the commands of a group ended: failed if any failed,
+// done if all succeeded and stopped otherwise
+func (g *Group) result() CommandStatus {
+	result := StatusDone
+	for _, cmd := range g.List() {
+		switch cmd.GetStatus() {
+		case StatusDone:
+		case StatusFailed:
+			return StatusFailed
+		default:
+			result = StatusStopped
+		}
+	}
+	return result
+}
+
+// setStep records the step a sequential group is at
+func (g *Group) setStep(step int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.step = step
+}
+
+// StartSet starts the commands of a config command set according to its mode
+func (e *Executor) StartSet(key string, set config.CommandSet) *Group {
+	mode := set.Mode
+	if mode == "" {
+		mode = config.ModeParallel
+	}
+
+	ctx, cancel := context.WithCancel(e.ctx)
+	group := &Group{
+		Key:             key,
+		Name:            set.Name,
+		Mode:            mode,
+		ContinueOnError: set.ContinueOnError,
+		hooks:           set.Hooks,
+		dir:             set.Dir,
+		env:             set.Environment(),
+		done:            make(chan struct{}),
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+	if group.Name == "" {
+		group.Name = key
+	}
+	if group.dir == "" {
+		group.dir = "."
+	}
+
+	// Commands of a parallel set that are already running on their own
+	// join the group as they are
+	adopted := make(map[*Command]bool)
+	for i, entry := range set.Entries(key) {
+		var cmd *Command
+		if mode == config.ModeParallel {
+			cmd = e.adopt(entry)
+		}
+		if cmd == nil {
+			cmd = e.register(entry)
+		} else {
+			adopted[cmd] = true
+		}
+		cmd.setGroup(group, i+1)
+		group.Commands = append(group.Commands, cmd)
+	}
+
+	e.mu.Lock()
+	e.groups = append(e.groups, group)
+	e.mu.Unlock()
+
+	go e.superviseGroup(group, adopted)
+	return group
+}
+
+// superviseGroup starts the commands of a group according to its mode and
+// runs the hooks of its set once around them: pre_start before the first
+// command starts, post_start right after, and the rest when every command
+// has finished. Restarts of single commands do not run them again.
+func (e *Executor) superviseGroup(group *Group, adopted map[*Command]bool) {
+	defer close(group.done)
+
+	err := e.runSetHooks(group, config.HookPreStart, StatusPending)
+	if err != nil && group.hooks.Blocking {
+		for _, cmd := range group.List() {
+			cmd.setError(err)
+		}
+	} else if group.ctx.Err() == nil {
+		var runner sync.WaitGroup
+		switch group.Mode {
+		case config.ModeSequential:
+			runner.Add(1)
+			go func() {
+				defer runner.Done()
+				e.runSequential(group)
+			}()
+		case config.ModeRace:
+			runner.Add(1)
+			go func() {
+				defer runner.Done()
+				e.runRace(group)
+			}()
+		default:
+			for _, cmd := range group.List() {
+				if !adopted[cmd] {
+					e.launch(cmd)
+				}
+			}
+		}
+
+		// A failing blocking post_start hook takes the set down
+		if err := e.runSetHooks(group, config.HookPostStart, StatusRunning); err != nil && group.hooks.Blocking {
+			group.cancel()
+			for _, cmd := range group.List() {
+				e.StopCommand(cmd.ID)
+			}
+		}
+
+		runner.Wait()
+		group.wait()
+	}
+
+	result := group.result()
+	switch result {
+	case StatusDone:
+		e.runSetHooks(group, config.HookOnSuccess, result)
+	case StatusFailed:
+		e.runSetHooks(group, config.HookOnFailure, result)
+	}
+	e.runSetHooks(group, config.HookPostStop, result)
+}
+
+// RestartSet starts a command set, replacing the commands of an earlier
+// run of the same set
+func (e *Executor) RestartSet(key string, set config.CommandSet) *Group {
+	if group := e.getGroup(key); group != nil {
+		e.removeGroup(group)
+	}
+	return e.StartSet(key, set)
+}
+
+// runSequential runs the commands of a group one after another
+func (e *Executor) runSequential(group *Group) {
+	cmds := group.List()
+	for i, cmd := range cmds {
+		if group.ctx.Err() != nil {
+			return
+		}
+
+		group.setStep(i + 1)
+		if !cmd.launched() {
+			e.launch(cmd)
+		}
+		cmd.Wait()
+
+		if cmd.GetStatus() == StatusDone || group.ContinueOnError {
+			continue
+		}
+
+		// Skip the remaining steps after a failure
+		for _, rest := range cmds[i+1:] {
+			if !rest.launched() {
+				rest.setStatus(StatusSkipped)
+				rest.addMessage("[cmdpool] skipped: " + cmd.Name + " did not succeed")
+			}
+		}
+		return
+	}
+}
+
+// runRace runs the commands of a group at once and stops the others when
+// the first one finishes
+func (e *Executor) runRace(group *Group) {
+	cmds := group.List()
+	finished := make(chan *Command, len(cmds))
+	for _, cmd := range cmds {
+		e.launch(cmd)
+		go func(cmd *Command) {
+			cmd.Wait()
+			finished <- cmd
+		}(cmd)
+	}
+
+	winner := <-finished
+	if group.ctx.Err() != nil {
+		return
+	}
+
+	winner.addMessage("[cmdpool] finished first, stopping the others")
+	for _, cmd := range cmds {
+		if cmd != winner {
+			e.StopCommand(cmd.ID)
+		}
+	}
+}
+
+// removeGroup stops the commands of a group and forgets about them. The
+// pre_stop hooks of the set run first, and the commands are only removed
+// once the hooks run after the set finished are done writing to them.
+func (e *Executor) removeGroup(group *Group) {
+	if group.running() {
+		e.runSetHooks(group, config.HookPreStop, StatusRunning)
+	}
+	group.cancel()
+
+	cmds := group.List()
+	for _, cmd := range cmds {
+		e.StopCommand(cmd.ID)
+	}
+	<-group.done
+	for _, cmd := range cmds {
+		e.RemoveCommand(cmd.ID)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, other := range e.groups {
+		if other == group {
+			e.groups = append(e.groups[:i:i], e.groups[i+1:]...)
+			break
+		}
+	}
+}
+
+// getGroup returns the group started from a command set key or nil
+func (e *Executor) getGroup(key string) *Group {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, group := range e.groups {
+		if group.Key == key {
+			return group
+		}
+	}
+	return nil
+}
+
+// GetGroups returns all groups in the order they were started
+func (e *Executor) GetGroups() []*Group {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := make([]*Group, len(e.groups))
+	copy(result, e.groups)
+	return result
+}
@@ -0,0 +1,155 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+)
+
+// hookTimeout bounds how long a single hook may run
+const hookTimeout = time.Minute
+
+// SetHooks sets the global hooks, which run for every command before the
+// hooks of the command itself
+func (e *Executor) SetHooks(hooks *config.Hooks) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.hooks = hooks
+}
+
+// runHooks runs the global and command hooks of a stage one after another.
+// Their output is added to the command output. The first failing hook
+// stops the stage and its error is returned.
+func (e *Executor) runHooks(cmd *Command, stage string) error {
+	e.mu.RLock()
+	global := e.hooks
+	e.mu.RUnlock()
+
+	cmd.mu.RLock()
+	hooks := append(append([]string{}, global.Stage(stage)...), cmd.Hooks.Stage(stage)...)
+	dir := cmd.Dir
+	env := append(os.Environ(), cmd.Env...)
+	env = append(env, cmd.hookEnv(stage)...)
+	cmd.mu.RUnlock()
+
+	return runHookList(cmd, stage, hooks, dir, env)
+}
+
+// runSetHooks runs the hooks a command set has for a stage. They run once
+// for the whole set and their output goes to its first command. status is
+// passed to the hooks as CMDPOOL_STATUS.
+func (e *Executor) runSetHooks(group *Group, stage string, status CommandStatus) error {
+	hooks := group.hooks.Stage(stage)
+	cmds := group.List()
+	if len(hooks) == 0 || len(cmds) == 0 {
+		return nil
+	}
+
+	env := append(os.Environ(), group.env...)
+	env = append(env,
+		"CMDPOOL_HOOK="+stage,
+		"CMDPOOL_NAME="+group.Name,
+		"CMDPOOL_SET="+group.Key,
+		"CMDPOOL_STATUS="+string(status))
+	return runHookList(cmds[0], stage, hooks, group.dir, env)
+}
+
+// runHookList runs hooks one after another until one fails, whose error is
+// returned
+func runHookList(cmd *Command, stage string, hooks []string, dir string, env []string) error {
+	for _, hook := range hooks {
+		if err := runHook(cmd, stage, hook, dir, env); err != nil {
+			cmd.addMessage(fmt.Sprintf("[%s] %s failed: %v", stage, hook, err))
+			return fmt.Errorf("%s hook %q failed: %w", stage, hook, err)
+		}
+	}
+	return nil
+}
+
+// runHook runs a single hook command and streams its output into the panel
+func runHook(cmd *Command, stage, hook, dir string, env []string) error {
+	args := parseCommand(hook)
+	if len(args) == 0 {
+		return fmt.Errorf("empty hook")
+	}
+
+	// Hooks also run while cmdpool shuts down, so they do not use the
+	// executor context
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	hookCmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	hookCmd.Dir = dir
+	hookCmd.Env = env
+
+	reader, writer := io.Pipe()
+	hookCmd.Stdout = writer
+	hookCmd.Stderr = writer
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			cmd.addMessage(fmt.Sprintf("[%s] %s", stage, scanner.Text()))
+		}
+		io.Copy(io.Discard, reader)
+	}()
+
+	err := hookCmd.Run()
+	writer.Close()
+	wg.Wait()
+	return err
+}
+
+// hasHooks reports whether a stage has any hooks for the command
+func (e *Executor) hasHooks(cmd *Command, stage string) bool {
+	e.mu.RLock()
+	global := e.hooks
+	e.mu.RUnlock()
+
+	cmd.mu.RLock()
+	defer cmd.mu.RUnlock()
+	return len(global.Stage(stage)) > 0 || len(cmd.Hooks.Stage(stage)) > 0
+}
+
+// hooksBlocking reports whether failing start hooks fail the command
+func (e *Executor) hooksBlocking(cmd *Command) bool {
+	e.mu.RLock()
+	global := e.hooks
+	e.mu.RUnlock()
+
+	cmd.mu.RLock()
+	defer cmd.mu.RUnlock()
+	return (global != nil && global.Blocking) || (cmd.Hooks != nil && cmd.Hooks.Blocking)
+}
+
+// hookEnv returns the CMDPOOL_* variables passed to hooks. The caller
+// must hold the command lock.
+func (c *Command) hookEnv(stage string) []string {
+	env := []string{
+		"CMDPOOL_HOOK=" + stage,
+		"CMDPOOL_NAME=" + c.Name,
+		"CMDPOOL_COMMAND=" + c.Command,
+		"CMDPOOL_STATUS=" + string(c.Status),
+	}
+	if c.group != nil {
+		env = append(env, "CMDPOOL_SET="+c.group.Key)
+	}
+	if c.Process != nil {
+		env = append(env, "CMDPOOL_PID="+strconv.Itoa(c.Process.Pid))
+	}
+	if c.ExitCode != nil {
+		env = append(env, "CMDPOOL_EXIT_CODE="+strconv.Itoa(*c.ExitCode))
+	}
+	return env
+}
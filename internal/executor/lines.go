@@ -0,0 +1,138 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultMaxLineLength is the longest output line kept, in bytes, unless
+// the config sets global.max_line_length
+const DefaultMaxLineLength = 256 * 1024
+
+// progressInterval is how often a line redrawn with \r is taken while it
+// keeps changing, so progress bars do not flood the output
+const progressInterval = time.Second
+
+// readBufferSize is how much output is read at once
+const readBufferSize = 32 * 1024
+
+// lineSplitter cuts raw output into lines. Lines longer than max are
+// truncated and the rest up to the newline is only counted. A bare \r
+// ends a progress update, which is kept at most once per progressInterval;
+// \r\n ends a line like \n.
+type lineSplitter struct {
+	max  int
+	emit func(string)
+	line []byte
+	// dropped counts the bytes cut off the current line
+	dropped int
+	// cr is set after a \r until the next byte tells whether it ended the
+	// line or a progress update
+	cr bool
+	// progressed is when the last progress update was taken
+	progressed time.Time
+}
+
+// readLines reads r until it ends and calls emit with every line, the last
+// one even without a newline. It returns the error reading stopped with,
+// or nil at the end of the output.
+func readLines(r io.Reader, max int, emit func(string)) error {
+	if max <= 0 {
+		max = DefaultMaxLineLength
+	}
+	s := &lineSplitter{max: max, emit: emit}
+
+	// Read whatever is available instead of waiting for a newline, so
+	// progress updates show up while the line is still being drawn
+	buf := make([]byte, readBufferSize)
+	for {
+		n, err := r.Read(buf)
+		for _, b := range buf[:n] {
+			s.put(b)
+		}
+		if err != nil {
+			s.flush()
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// put adds a byte of output
+func (s *lineSplitter) put(b byte) {
+	switch {
+	case b == '\n':
+		s.cr = false
+		s.end()
+		return
+	case s.cr:
+		s.cr = false
+		s.progress()
+	}
+
+	if b == '\r' {
+		s.cr = true
+		return
+	}
+	if len(s.line) >= s.max {
+		s.dropped++
+		return
+	}
+	s.line = append(s.line, b)
+}
+
+// progress ends a progress update, taking it if the last one taken is old
+// enough
+func (s *lineSplitter) progress() {
+	if len(s.line) > 0 && time.Since(s.progressed) >= progressInterval {
+		s.progressed = time.Now()
+		s.end()
+	}
+	s.line = s.line[:0]
+	s.dropped = 0
+}
+
+// flush emits what is left once the output ended
+func (s *lineSplitter) flush() {
+	s.cr = false
+	if len(s.line) > 0 || s.dropped > 0 {
+		s.end()
+	}
+}
+
+// end emits the current line and starts the next one
+func (s *lineSplitter) end() {
+	s.emit(cleanLine(s.line, s.dropped))
+	s.line = s.line[:0]
+	s.dropped = 0
+}
+
+// cleanLine turns raw line bytes into text that can be shown: invalid
+// UTF-8 becomes U+FFFD, NUL bytes become ␀ and a truncated line ends with
+// the number of bytes cut off
+func cleanLine(line []byte, dropped int) string {
+	if dropped > 0 {
+		// Do not leave half a character where the line was cut
+		for i := 1; i < utf8.UTFMax && i <= len(line); i++ {
+			if start := len(line) - i; utf8.RuneStart(line[start]) {
+				if !utf8.FullRune(line[start:]) {
+					dropped += i
+					line = line[:start]
+				}
+				break
+			}
+		}
+	}
+
+	text := strings.ToValidUTF8(string(line), "�")
+	text = strings.ReplaceAll(text, "\x00", "␀")
+	if dropped > 0 {
+		text += fmt.Sprintf(" … [%d bytes truncated]", dropped)
+	}
+	return text
+}
@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// chunkReader returns one chunk per Read call and then err, or io.EOF
+type chunkReader struct {
+	chunks []string
+	err    error
+}
+
+// Read returns what is left of the current chunk
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	if r.chunks[0] = r.chunks[0][n:]; r.chunks[0] == "" {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+func TestReadLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		max    int
+		want   []string
+	}{
+		{"lines", []string{"one\ntwo\n"}, 0, []string{"one", "two"}},
+		{"partial lines across reads", []string{"hel", "lo\nwor", "ld\n"}, 0, []string{"hello", "world"}},
+		{"last line without newline", []string{"one\ntwo"}, 0, []string{"one", "two"}},
+		{"empty lines", []string{"\n\n"}, 0, []string{"", ""}},
+		{"no output", nil, 0, nil},
+		{"crlf", []string{"one\r\ntwo\r\n"}, 0, []string{"one", "two"}},
+		{"crlf split across reads", []string{"one\r", "\ntwo\n"}, 0, []string{"one", "two"}},
+		{"progress updates", []string{"10%\r20%\r30%\rdone\n"}, 0, []string{"10%", "done"}},
+		{"progress before end", []string{"a\rb"}, 0, []string{"a", "b"}},
+		{"cr at end", []string{"abc\r"}, 0, []string{"abc"}},
+		{"truncated", []string{"abcdefgh\nok\n"}, 4, []string{"abcd … [4 bytes truncated]", "ok"}},
+		{"truncated at end", []string{"abcdefgh"}, 4, []string{"abcd … [4 bytes truncated]"}},
+		{"truncated inside a character", []string{"aé€x\n"}, 5, []string{"aé … [4 bytes truncated]"}},
+		{"invalid utf-8", []string{"a\xffb\n"}, 0, []string{"a�b"}},
+		{"nul bytes", []string{"a\x00b\n"}, 0, []string{"a␀b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := readLines(&chunkReader{chunks: tt.chunks}, tt.max, func(line string) {
+				got = append(got, line)
+			})
+			if err != nil {
+				t.Fatalf("readLines() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLinesFlushesOnError(t *testing.T) {
+	readErr := errors.New("read failed")
+	var got []string
+	err := readLines(&chunkReader{chunks: []string{"one\ntw"}, err: readErr}, 0, func(line string) {
+		got = append(got, line)
+	})
+	if !errors.Is(err, readErr) {
+		t.Errorf("readLines() error = %v, want %v", err, readErr)
+	}
+	if want := []string{"one", "tw"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
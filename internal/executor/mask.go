@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+)
+
+// maskText replaces secrets in output
+const maskText = "********"
+
+// minSecretLength is the shortest variable value masked; shorter values
+// such as "1" or "on" would mask unrelated text everywhere
+const minSecretLength = 4
+
+// masking holds the compiled mask config of the executor
+type masking struct {
+	env      []string
+	patterns []*regexp.Regexp
+}
+
+// masker hides the secrets of one command: the values of its secret
+// variables and whatever the mask patterns match
+type masker struct {
+	values   []string
+	patterns []*regexp.Regexp
+}
+
+// SetMasking sets which secrets are masked in output. A nil config masks
+// the variables matching the built-in name patterns.
+func (e *Executor) SetMasking(mask *config.MaskConfig) {
+	m := masking{env: mask.EnvPatterns()}
+	if mask != nil {
+		for _, pattern := range mask.Patterns {
+			// Patterns were checked when the config was loaded
+			if re, err := regexp.Compile(pattern); err == nil {
+				m.patterns = append(m.patterns, re)
+			}
+		}
+	}
+
+	e.mu.Lock()
+	e.masking = m
+	e.mu.Unlock()
+
+	for _, cmd := range e.List() {
+		e.updateMasker(cmd)
+	}
+}
+
+// updateMasker builds the masker of a command from its environment, which
+// is that of cmdpool plus the variables of the command
+func (e *Executor) updateMasker(cmd *Command) {
+	e.mu.RLock()
+	m := e.masking
+	e.mu.RUnlock()
+
+	cmd.mu.Lock()
+	defer cmd.mu.Unlock()
+	cmd.masker = m.masker(append(os.Environ(), cmd.Env...))
+}
+
+// masker returns the masker for an environment, or nil if there is
+// nothing to mask
+func (m masking) masker(env []string) *masker {
+	var values []string
+	seen := make(map[string]bool)
+	for _, v := range env {
+		name, value, _ := strings.Cut(v, "=")
+		if len(value) >= minSecretLength && !seen[value] && config.MatchesEnv(m.env, name) {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 && len(m.patterns) == 0 {
+		return nil
+	}
+	return &masker{values: values, patterns: m.patterns}
+}
+
+// mask returns text with its secrets replaced. A nil masker masks nothing.
+func (m *masker) mask(text string) string {
+	if m == nil {
+		return text
+	}
+	text = maskValues(text, m.values)
+	for _, re := range m.patterns {
+		text = maskMatches(re, text)
+	}
+	return text
+}
+
+// maskValues replaces every occurrence of the values. Occurrences that
+// overlap, such as those of a secret containing another, are masked as
+// one, so no part of either is left.
+func maskValues(text string, values []string) string {
+	var spans [][2]int
+	for _, value := range values {
+		for i := 0; ; {
+			j := strings.Index(text[i:], value)
+			if j < 0 {
+				break
+			}
+			spans = append(spans, [2]int{i + j, i + j + len(value)})
+			i += j + 1
+		}
+	}
+	if len(spans) == 0 {
+		return text
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	var b strings.Builder
+	last, start, end := 0, spans[0][0], spans[0][1]
+	for _, span := range spans[1:] {
+		if span[0] < end {
+			end = max(end, span[1])
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(maskText)
+		last, start, end = end, span[0], span[1]
+	}
+	b.WriteString(text[last:start])
+	b.WriteString(maskText)
+	b.WriteString(text[end:])
+	return b.String()
+}
+
+// maskMatches replaces the matches of a pattern, or only their groups if
+// the pattern has any
+func maskMatches(re *regexp.Regexp, text string) string {
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		spans := match[:2]
+		if len(match) > 2 {
+			spans = match[2:]
+		}
+		for i := 0; i < len(spans); i += 2 {
+			start, end := spans[i], spans[i+1]
+			// Groups that did not take part in the match, or that overlap
+			// one already masked, are left alone
+			if start < last || start == end {
+				continue
+			}
+			b.WriteString(text[last:start])
+			b.WriteString(maskText)
+			last = end
+		}
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// probeInterval is the pause between two readiness checks
+const probeInterval = time.Second
+
+// runProbe checks the command's readiness probe until it succeeds or the
+// command exits. Supported probes are "tcp://host:port", http(s) URLs
+// (any status below 400 counts as ready) and plain command lines that
+// must exit with status 0.
+func runProbe(ctx context.Context, cmd *Command) {
+	for {
+		if cmd.GetStatus() != StatusRunning {
+			return
+		}
+
+		if err := checkProbe(ctx, cmd); err == nil {
+			cmd.mu.Lock()
+			cmd.Ready = true
+			cmd.mu.Unlock()
+			cmd.addMessage("[cmdpool] ready")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(probeInterval):
+		}
+	}
+}
+
+// checkProbe runs a single readiness check
+func checkProbe(ctx context.Context, cmd *Command) error {
+	ctx, cancel := context.WithTimeout(ctx, probeInterval)
+	defer cancel()
+
+	switch {
+	case strings.HasPrefix(cmd.Probe, "tcp://"):
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", strings.TrimPrefix(cmd.Probe, "tcp://"))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	case strings.HasPrefix(cmd.Probe, "http://"), strings.HasPrefix(cmd.Probe, "https://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, cmd.Probe, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("probe returned %s", resp.Status)
+		}
+		return nil
+
+	default:
+		args := parseCommand(cmd.Probe)
+		if len(args) == 0 {
+			return fmt.Errorf("empty probe")
+		}
+		probe := exec.CommandContext(ctx, args[0], args[1:]...)
+		probe.Dir = cmd.Dir
+		probe.Env = append(os.Environ(), cmd.Env...)
+		return probe.Run()
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package executor
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// openPty is not available on this platform
+func openPty() (master, slave *os.File, err error) {
+	return nil, nil, errors.New("running commands in a pty is not supported on this platform")
+}
+
+// ptyAttr is not needed without pseudo terminals
+func ptyAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// ptyClosed reports false, as there are no pseudo terminals
+func ptyClosed(err error) bool {
+	return false
+}
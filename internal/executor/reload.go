@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"reflect"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+)
+
+// Reload applies the differences between two versions of a config.
+// Commands whose definition changed are restarted, removed ones are stopped
+// and new ones are started; everything else keeps running untouched.
+func (e *Executor) Reload(old, new *config.Config) config.Diff {
+	diff := config.Compare(old, new)
+	e.SetHooks(new.Global.Hooks)
+	e.SetMaxLineLength(new.Global.MaxLineLength)
+	e.SetOutputLimits(new.Global.MaxOutput, new.Global.MaxOutputBytes, new.Global.SpillOutput)
+	e.SetAlerts(new.Alerts)
+	e.SetMasking(new.Global.Mask)
+
+	for _, key := range diff.RemovedSets {
+		if group := e.getGroup(key); group != nil {
+			e.removeGroup(group)
+		}
+	}
+
+	for _, key := range diff.RestartedSets {
+		if group := e.getGroup(key); group != nil {
+			e.removeGroup(group)
+		}
+		e.StartSet(key, new.CommandSets[key])
+	}
+
+	for _, name := range diff.Removed {
+		e.RemoveCommand(name)
+	}
+
+	for _, change := range diff.Changed {
+		e.replaceCommand(change.Entry)
+	}
+
+	for _, change := range diff.Added {
+		e.addToGroup(change.Set, change.Entry)
+	}
+
+	for _, key := range diff.AddedSets {
+		e.StartSet(key, new.CommandSets[key])
+	}
+
+	return diff
+}
+
+// replaceCommand restarts a command with a new definition, keeping its output
+func (e *Executor) replaceCommand(entry config.CommandEntry) {
+	cmd := e.getCommand(entry.Name)
+	if cmd == nil {
+		e.Start(entry)
+		return
+	}
+
+	cmd.setRestarting(true)
+	defer cmd.setRestarting(false)
+	e.StopCommand(cmd.ID)
+	cmd.stopWatch()
+	cmd.apply(entry)
+	e.updateMasker(cmd)
+	cmd.reset()
+	cmd.addMessage("[cmdpool] definition changed, restarting")
+	e.launch(cmd)
+}
+
+// addToGroup starts a new command as part of the group of a command set.
+// A command started on its own with the same definition joins the group
+// instead, as happens when a command added in the TUI is saved to the config.
+func (e *Executor) addToGroup(key string, entry config.CommandEntry) {
+	cmd := e.adopt(entry)
+	adopted := cmd != nil
+	if !adopted {
+		cmd = e.register(entry)
+	}
+
+	if group := e.getGroup(key); group != nil {
+		group.mu.Lock()
+		group.Commands = append(group.Commands, cmd)
+		step := len(group.Commands)
+		group.mu.Unlock()
+		cmd.setGroup(group, step)
+	}
+
+	if !adopted {
+		e.launch(cmd)
+	}
+}
+
+// adopt returns the command named like entry if it belongs to no group and
+// was created from the same definition
+func (e *Executor) adopt(entry config.CommandEntry) *Command {
+	cmd := e.getCommand(entry.Name)
+	if cmd == nil {
+		return nil
+	}
+
+	cmd.mu.RLock()
+	defer cmd.mu.RUnlock()
+	if cmd.group != nil || !reflect.DeepEqual(cmd.entry, entry) {
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,81 @@
+package executor
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+)
+
+// commandLines returns the name and command line of every command of e
+func commandLines(e *Executor) map[string]string {
+	result := make(map[string]string)
+	for _, cmd := range e.List() {
+		cmd.mu.RLock()
+		result[cmd.Name] = cmd.Command
+		cmd.mu.RUnlock()
+	}
+	return result
+}
+
+func TestReload(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sleep")
+	}
+
+	old := &config.Config{CommandSets: map[string]config.CommandSet{
+		"web": {Commands: []config.CommandEntry{
+			{Name: "api", Run: "sleep 30"},
+			{Name: "ui", Run: "sleep 31"},
+		}},
+		"tools": {Commands: []config.CommandEntry{{Name: "lint", Run: "sleep 32"}}},
+	}}
+	next := &config.Config{CommandSets: map[string]config.CommandSet{
+		"web": {Commands: []config.CommandEntry{
+			{Name: "api", Run: "sleep 33"},
+			{Name: "docs", Run: "sleep 34"},
+		}},
+		"db": {Commands: []config.CommandEntry{{Name: "db", Run: "sleep 35"}}},
+	}}
+
+	e := NewExecutor()
+	defer e.Stop()
+	for _, key := range old.SetNames() {
+		e.StartSet(key, old.CommandSets[key])
+	}
+	api := e.getCommand("api")
+
+	diff := e.Reload(old, next)
+	if got, want := diff.Summary(), "2 started, 1 restarted, 2 stopped"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+
+	want := map[string]string{"api": "sleep 33", "docs": "sleep 34", "db": "sleep 35"}
+	if got := commandLines(e); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands = %v, want %v", got, want)
+	}
+	if e.getCommand("api") != api {
+		t.Errorf("api was replaced, want it restarted in place")
+	}
+
+	var keys []string
+	for _, group := range e.GetGroups() {
+		keys = append(keys, group.Key)
+	}
+	if want := []string{"web", "db"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("groups = %q, want %q", keys, want)
+	}
+
+	docs := e.getCommand("docs")
+	if group, step := docs.Group(), docs.Step(); group == nil || group.Key != "web" || step != 2 {
+		t.Errorf("docs is step %d of %v, want step 2 of web", step, group)
+	}
+	var names []string
+	for _, cmd := range e.getGroup("web").List() {
+		names = append(names, cmd.Name)
+	}
+	if want := []string{"api", "docs"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("web commands = %q, want %q", names, want)
+	}
+}
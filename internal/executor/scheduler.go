@@ -0,0 +1,154 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+)
+
+// runSchedule supervises a scheduled command: it waits for each fire time
+// and starts a run, applying the overlap policy when the previous run is
+// still active. It returns when ctx is cancelled.
+func (e *Executor) runSchedule(ctx context.Context, cmd *Command) {
+	cmd.mu.RLock()
+	expr, every, overlap := cmd.Schedule, cmd.Every, cmd.Overlap
+	cmd.mu.RUnlock()
+
+	sched, err := parseSchedule(expr, every)
+	if err != nil {
+		cmd.setError(err)
+		return
+	}
+
+	// finished is closed when the current run ends and nil while idle
+	var finished chan struct{}
+	var cancelRun context.CancelFunc
+	queued := false
+
+	startRun := func(reason string) {
+		cmd.reset()
+		cmd.addMessage(reason)
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		finished, cancelRun = done, cancel
+		go func() {
+			defer close(done)
+			defer cancel()
+			e.supervise(runCtx, cmd)
+		}()
+	}
+
+	endRun := func() {
+		<-finished
+		finished = nil
+		cmd.recordRun()
+	}
+
+	cmd.setStatus(StatusScheduled)
+	if cmd.takeRunNow() {
+		startRun("[cmdpool] run started by hand")
+	}
+
+	next := sched.Next(time.Now())
+	for {
+		if next.IsZero() {
+			cmd.addMessage("[cmdpool] schedule never fires again")
+			next = time.Now().Add(24 * time.Hour * 365)
+		}
+		cmd.setNextRun(next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if finished != nil {
+				<-finished
+			}
+			cmd.setNextRun(time.Time{})
+			cmd.setStopped()
+			return
+
+		case <-finished:
+			timer.Stop()
+			endRun()
+			if queued {
+				queued = false
+				startRun("[cmdpool] queued run")
+			}
+			continue
+
+		case <-timer.C:
+		}
+
+		// Skip fire times missed while the machine was asleep
+		fired := next
+		for next = sched.Next(next); !next.IsZero() && next.Before(time.Now()); {
+			next = sched.Next(next)
+		}
+
+		if finished == nil {
+			startRun(fmt.Sprintf("[cmdpool] scheduled run at %s", fired.Format("15:04:05")))
+			continue
+		}
+
+		switch overlap {
+		case config.OverlapQueue:
+			if !queued {
+				queued = true
+				cmd.addMessage("[cmdpool] previous run still active, next run queued")
+			}
+		case config.OverlapReplace:
+			cmd.addMessage("[cmdpool] previous run still active, replacing it")
+			cancelRun()
+			endRun()
+			startRun(fmt.Sprintf("[cmdpool] scheduled run at %s", fired.Format("15:04:05")))
+		default:
+			cmd.addMessage(fmt.Sprintf("[cmdpool] skipped run at %s, previous run still active", fired.Format("15:04:05")))
+		}
+	}
+}
+
+// recordRun stores the result of a finished scheduled run and marks the
+// command as waiting for the next one
+func (c *Command) recordRun() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.LastRun = c.StartTime
+	c.LastResult = c.Status
+	c.Status = StatusScheduled
+}
+
+// setNextRun records the next fire time of a scheduled command
+func (c *Command) setNextRun(next time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.NextRun = next
+}
+
+// takeRunNow returns and clears a request to run a scheduled command
+// immediately
+func (c *Command) takeRunNow() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	runNow := c.runNow
+	c.runNow = false
+	return runNow
+}
+
+// isScheduled reports whether the command runs on a schedule
+func (c *Command) isScheduled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Schedule != "" || c.Every > 0
+}
+
+// GetSchedule returns the last run time and result and the next fire time
+// of a scheduled command
+func (c *Command) GetSchedule() (lastRun time.Time, lastResult CommandStatus, nextRun time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.LastRun, c.LastResult, c.NextRun
+}
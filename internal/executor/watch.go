@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/pashkov256/cmdpool/internal/config"
+	"github.com/pashkov256/cmdpool/internal/watcher"
+)
+
+// startWatch starts watching the files of a command with a watch config.
+// The watcher lives until the command is removed or redefined.
+func (e *Executor) startWatch(cmd *Command) {
+	cmd.mu.Lock()
+	if cmd.Watch == nil || cmd.watchCancel != nil {
+		cmd.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(e.ctx)
+	cmd.watchCancel = cancel
+	watch := *cmd.Watch
+	dir := cmd.Dir
+	cmd.mu.Unlock()
+
+	// The paths are shared with the config and the other commands of the
+	// set, so they are resolved in a copy
+	roots := append([]string(nil), watch.Paths...)
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	for i, root := range roots {
+		if !filepath.IsAbs(root) {
+			roots[i] = filepath.Join(dir, root)
+		}
+	}
+
+	opts := watcher.Options{
+		Roots:    roots,
+		Include:  watch.Include,
+		Exclude:  watch.Exclude,
+		Debounce: watch.Debounce,
+	}
+	go watcher.Watch(ctx, opts, func(path string) {
+		e.onFileChange(cmd, watch.Action, path)
+	})
+}
+
+// stopWatch stops the file watcher of a command, if any
+func (c *Command) stopWatch() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.watchCancel != nil {
+		c.watchCancel()
+		c.watchCancel = nil
+	}
+}
+
+// onFileChange applies the watch action of a command after a file changed
+func (e *Executor) onFileChange(cmd *Command, action config.WatchAction, path string) {
+	status := cmd.GetStatus()
+	if status == StatusStopped || status == StatusSkipped {
+		// Leave commands the user stopped alone
+		return
+	}
+
+	if name, isSignal := action.Signal(); isSignal {
+		cmd.mu.RLock()
+		process := cmd.Process
+		cmd.mu.RUnlock()
+
+		sig, known := config.Signals[name]
+		switch {
+		case !known:
+			cmd.addMessage(fmt.Sprintf("[cmdpool] unknown signal %s", name))
+		case status != StatusRunning || process == nil:
+			return
+		default:
+			cmd.addMessage(fmt.Sprintf("[cmdpool] sending %s due to change in %s", name, path))
+			if err := process.Signal(sig); err != nil {
+				cmd.addMessage(fmt.Sprintf("[cmdpool] failed to send %s: %v", name, err))
+			}
+		}
+		return
+	}
+
+	if action == config.WatchRerun && status == StatusRunning {
+		cmd.mu.Lock()
+		cmd.rerun = fmt.Sprintf("[cmdpool] rerunning due to change in %s", path)
+		cmd.mu.Unlock()
+		return
+	}
+
+	e.restart(cmd, fmt.Sprintf("[cmdpool] restarting due to change in %s", path))
+}
+
+// takeRerun returns and clears a pending rerun request
+func (c *Command) takeRerun() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reason := c.rerun
+	c.rerun = ""
+	return reason
+}
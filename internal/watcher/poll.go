@@ -0,0 +1,79 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileState identifies a version of a file
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchPolling scans the roots every Interval and reports files that were
+// created, modified or removed
+func watchPolling(ctx context.Context, opts Options, events chan<- string) {
+	previous := scan(opts)
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := scan(opts)
+		for file, state := range current {
+			if old, exists := previous[file]; !exists || old != state {
+				send(ctx, events, file)
+			}
+		}
+		for file := range previous {
+			if _, exists := current[file]; !exists {
+				send(ctx, events, file)
+			}
+		}
+		previous = current
+	}
+}
+
+// scan records the state of every included file below the roots
+func scan(opts Options) map[string]fileState {
+	files := make(map[string]fileState)
+	for _, root := range opts.Roots {
+		opts.walkDirs(root, root, func(dir string) error {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				return nil
+			}
+			for _, entry := range entries {
+				if entry.IsDir() {
+					continue
+				}
+				file := filepath.Join(dir, entry.Name())
+				if rel, _ := filepath.Rel(root, file); !opts.included(rel) {
+					continue
+				}
+				if info, err := entry.Info(); err == nil {
+					files[file] = fileState{modTime: info.ModTime(), size: info.Size()}
+				}
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// send reports a change unless the watcher is shutting down
+func send(ctx context.Context, events chan<- string, file string) {
+	select {
+	case events <- file:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,132 @@
+//go:build linux
+
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyMask selects the events that indicate a changed file
+const inotifyMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_MODIFY | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ONLYDIR
+
+// inotifyWatcher holds one inotify instance watching directories
+type inotifyWatcher struct {
+	fd    int
+	opts  Options
+	dirs  map[int]string
+	roots map[int]string
+}
+
+// watchNative watches the roots with inotify. One watch is added per
+// directory rather than per file; excluded directories are never watched.
+// It returns an error when inotify is unavailable or runs out of watches,
+// so the caller can fall back to polling.
+func watchNative(ctx context.Context, opts Options, events chan<- string) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("inotify: %w", err)
+	}
+
+	// A non-blocking descriptor wrapped in an os.File uses the runtime
+	// poller, so closing the file interrupts a pending Read
+	file := os.NewFile(uintptr(fd), "inotify")
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		file.Close()
+	}()
+
+	w := &inotifyWatcher{
+		fd:    fd,
+		opts:  opts,
+		dirs:  make(map[int]string),
+		roots: make(map[int]string),
+	}
+	for _, root := range opts.Roots {
+		if err := w.addTree(root, root); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := file.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("inotify: %w", err)
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+
+			if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+				return errors.New("inotify: event queue overflow")
+			}
+
+			dir, known := w.dirs[int(event.Wd)]
+			if !known {
+				continue
+			}
+			if event.Mask&unix.IN_IGNORED != 0 {
+				delete(w.dirs, int(event.Wd))
+				delete(w.roots, int(event.Wd))
+				continue
+			}
+
+			name := string(nameBytes)
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+			path := filepath.Join(dir, name)
+			root := w.roots[int(event.Wd)]
+			rel, _ := filepath.Rel(root, path)
+
+			if event.Mask&unix.IN_ISDIR != 0 {
+				// Watch directories created after startup as well
+				if event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 && !w.opts.excluded(rel) {
+					if err := w.addTree(root, path); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			if w.opts.included(rel) {
+				send(ctx, events, path)
+			}
+		}
+	}
+}
+
+// addTree adds a watch for dir and every directory below it
+func (w *inotifyWatcher) addTree(root, dir string) error {
+	return w.opts.walkDirs(root, dir, func(path string) error {
+		wd, err := unix.InotifyAddWatch(w.fd, path, inotifyMask)
+		if err != nil {
+			if errors.Is(err, unix.ENOSPC) {
+				return fmt.Errorf("inotify: out of watches at %s (raise fs.inotify.max_user_watches)", path)
+			}
+			// The directory may have been removed in the meantime
+			return nil
+		}
+		w.dirs[wd] = path
+		w.roots[wd] = root
+		return nil
+	})
+}
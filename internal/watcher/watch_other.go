@@ -0,0 +1,13 @@
+//go:build !linux
+
+package watcher
+
+import (
+	"context"
+	"errors"
+)
+
+// watchNative is not available on this platform; Watch falls back to polling
+func watchNative(ctx context.Context, opts Options, events chan<- string) error {
+	return errors.New("native file watching is not supported on this platform")
+}
@@ -0,0 +1,164 @@
+// Package watcher reports file changes below a set of directories. It uses
+// inotify on Linux and falls back to polling elsewhere, or when the system
+// runs out of inotify watches.
+package watcher
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Defaults used when Options leaves a field empty
+const (
+	DefaultDebounce = 300 * time.Millisecond
+	DefaultInterval = time.Second
+)
+
+// DefaultExclude lists directories that are never watched
+var DefaultExclude = []string{".git", ".hg", ".svn", "node_modules"}
+
+// Options configures a watcher
+type Options struct {
+	// Roots are the directories to watch recursively
+	Roots []string
+	// Include limits reported files to those matching one of the patterns.
+	// Patterns without a slash match the file name, others the path
+	// relative to the root; "**" matches any number of directories.
+	Include []string
+	// Exclude skips files and directories matching one of the patterns
+	Exclude []string
+	// Debounce is how long to wait for more changes before reporting
+	Debounce time.Duration
+	// Interval is the polling interval of the fallback watcher
+	Interval time.Duration
+}
+
+// Watch calls onChange with the path of a changed file (its root joined
+// with the path relative to it) once per burst of changes. It blocks until
+// ctx is cancelled.
+func Watch(ctx context.Context, opts Options, onChange func(path string)) {
+	if opts.Debounce <= 0 {
+		opts.Debounce = DefaultDebounce
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	opts.Exclude = append(append([]string{}, opts.Exclude...), DefaultExclude...)
+
+	events := make(chan string, 64)
+	go func() {
+		if err := watchNative(ctx, opts, events); err != nil && ctx.Err() == nil {
+			watchPolling(ctx, opts, events)
+		}
+	}()
+
+	var first string
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case changed := <-events:
+			if first == "" {
+				first = changed
+			}
+			timer = time.After(opts.Debounce)
+		case <-timer:
+			onChange(first)
+			first = ""
+			timer = nil
+		}
+	}
+}
+
+// excluded reports whether a path relative to a root is excluded
+func (o Options) excluded(rel string) bool {
+	return matchAny(o.Exclude, rel)
+}
+
+// included reports whether a file relative to a root should be reported
+func (o Options) included(rel string) bool {
+	if o.excluded(rel) {
+		return false
+	}
+	return len(o.Include) == 0 || matchAny(o.Include, rel)
+}
+
+// walkDirs calls fn for start and every directory below it that is not
+// excluded; exclusion patterns are matched relative to root
+func (o Options) walkDirs(root, start string, fn func(dir string) error) error {
+	return filepath.WalkDir(start, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			// Directories can disappear while walking
+			if p == start {
+				return err
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if rel, _ := filepath.Rel(root, p); rel != "." && o.excluded(rel) {
+			return filepath.SkipDir
+		}
+		return fn(p)
+	})
+}
+
+// matchAny reports whether rel matches one of the patterns
+func matchAny(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash separated path against a pattern. Patterns
+// without a slash are matched against every path element, so "*.tmp" and
+// "build" match at any depth. "**" matches zero or more path elements.
+func matchGlob(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	if !strings.Contains(pattern, "/") {
+		for _, part := range strings.Split(rel, "/") {
+			if ok, _ := path.Match(pattern, part); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	return matchParts(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchParts matches path elements against pattern elements
+func matchParts(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchParts(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+
+	// A pattern matching a directory also matches everything below it
+	return true
+}
@@ -0,0 +1,71 @@
+package watcher
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		rel     string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/app/main.go", true},
+		{"*.go", "main.go.orig", false},
+		{"build", "build", true},
+		{"build", "web/build/app.js", true},
+		{"build/", "build/app.js", true},
+		{"./build", "build/app.js", true},
+		{"src/*.go", "src/main.go", true},
+		{"src/*.go", "lib/src/main.go", false},
+		{"src/*.go", "src/pkg/main.go", false},
+		{"src", "src/pkg/main.go", true},
+		{"src/pkg", "src/pkg/main.go", true},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/c/main.go", true},
+		{"**/*.go", "a/b/c/main.js", false},
+		{"src/**/*_test.go", "src/x_test.go", true},
+		{"src/**/*_test.go", "src/a/b/x_test.go", true},
+		{"src/**/*_test.go", "lib/a/x_test.go", false},
+		{"docs/**", "docs/a/b.md", true},
+		{"*_test.go", "pkg/x_test.go", true},
+		{"[ab].txt", "dir/a.txt", true},
+		{"[ab].txt", "dir/c.txt", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestOptionsIncluded(t *testing.T) {
+	opts := Options{
+		Include: []string{"**/*.go", "go.mod"},
+		Exclude: append([]string{"*_test.go", "vendor"}, DefaultExclude...),
+	}
+
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{"main.go", true},
+		{"go.mod", true},
+		{"internal/app/app.go", true},
+		{"internal/app/app_test.go", false},
+		{"vendor/github.com/x/x.go", false},
+		{"node_modules/pkg/index.go", false},
+		{".git/HEAD", false},
+		{"README.md", false},
+	}
+
+	for _, tt := range tests {
+		if got := opts.included(tt.rel); got != tt.want {
+			t.Errorf("included(%q) = %v, want %v", tt.rel, got, tt.want)
+		}
+	}
+
+	if !(Options{}).included("anything.txt") {
+		t.Errorf("included() = false without patterns, want true")
+	}
+}